@@ -0,0 +1,94 @@
+package main
+
+// Shows what a player thinks of a position: the most promising moves,
+// how much each was searched, how often it wins, and the line of play
+// it expects after each one.
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"lacker.info/hex"
+)
+
+func main() {
+	hex.Seed()
+
+	// Usage:
+	//   go run analyze.go [--player mcts5] [--top 10] [--json out.json] \
+	//     puzzleName
+	//   go run analyze.go [flags] --board boardJSON
+	//   go run analyze.go [flags] --record games.jsonl [--game 0] --ply 12
+
+	var playerp = flag.String("player", "mcts:seconds=5,quiet=true",
+		"the player to analyze with. it must be able to explain itself")
+	var topp = flag.Int("top", 10,
+		"how many candidate moves to show. 0 or less shows them all")
+	var jsonp = flag.String("json", "",
+		"also write the analysis as json to this file")
+	var boardp = flag.String("board", "", "a board in json to analyze")
+	var recordp = flag.String("record", "",
+		"a file of game records to analyze a position from")
+	var gamep = flag.Int("game", 0, "which game in the record file, from 0")
+	var plyp = flag.Int("ply", 0,
+		"how many moves into the game the position is")
+	var puzzlesp = flag.String("puzzles", "",
+		"a directory of .puzzle files to add to the built-in puzzles")
+
+	flag.Parse()
+	args := flag.Args()
+
+	var board *hex.NaiveBoard
+	var err error
+	switch {
+	case *boardp != "":
+		board, err = hex.ParseNaiveBoardJSON(*boardp)
+	case *recordp != "":
+		var records []hex.GameRecord
+		records, err = hex.ReadGameRecords(*recordp)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *gamep < 0 || *gamep >= len(records) {
+			log.Fatalf("%s has %d games", *recordp, len(records))
+		}
+		board, err = records[*gamep].BoardAtPly(*plyp)
+	case len(args) == 1:
+		if *puzzlesp != "" {
+			err = hex.AddPuzzleDir(*puzzlesp)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		var puzzle hex.Puzzle
+		puzzle, err = hex.LookupPuzzle(args[0])
+		board = puzzle.Board
+	default:
+		log.Fatal("usage: go run analyze.go [flags] " +
+			"(puzzleName | --board json | --record file --ply n)")
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	player, err := hex.LookupPlayer(*playerp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	analyzer, ok := player.(hex.Analyzer)
+	if !ok {
+		log.Fatalf("%s cannot analyze positions", *playerp)
+	}
+
+	board.Eprint()
+	analysis := analyzer.Analyze(board).Top(*topp)
+	fmt.Println(analysis.Report())
+	if *jsonp != "" {
+		err = ioutil.WriteFile(*jsonp, []byte(hex.ToJSON(analysis)), 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
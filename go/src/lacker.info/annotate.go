@@ -0,0 +1,74 @@
+package main
+
+// Goes through stored games with a strong player, marking how much
+// each move changed the win rate and flagging the blunders.
+// Prints a summary of each game, then totals for each player.
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+
+	"lacker.info/hex"
+)
+
+func main() {
+	hex.Seed()
+
+	// Usage:
+	//   go run annotate.go [--analyzer mcts:seconds=5,quiet=true] \
+	//     [--threshold 0.2] [--out annotated.jsonl] games.jsonl
+
+	var analyzerp = flag.String("analyzer", "mcts:seconds=5,quiet=true",
+		"the player that judges each position")
+	var thresholdp = flag.Float64("threshold", 0.2,
+		"a win rate drop of this much is a blunder")
+	var outp = flag.String("out", "annotated.jsonl",
+		"file to write the annotated game records to")
+
+	flag.Parse()
+	args := flag.Args()
+	if len(args) != 1 {
+		log.Fatal("usage: go run annotate.go [flags] games.jsonl")
+	}
+
+	records, err := hex.ReadGameRecords(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	annotator := hex.Annotator{Analyzer: *analyzerp, Threshold: *thresholdp}
+	totals := make(map[string]*hex.PlayerSummary)
+	annotated := make([]hex.GameRecord, 0)
+	for i, record := range records {
+		record, err = annotator.Annotate(record)
+		if err != nil {
+			log.Fatal(err)
+		}
+		annotated = append(annotated, record)
+		fmt.Printf("game %d: %s\n", i, hex.GameReport(record))
+		for name, summary := range hex.SummarizeGame(record) {
+			if totals[name] == nil {
+				totals[name] = &hex.PlayerSummary{}
+			}
+			totals[name].Add(*summary)
+		}
+
+		// Save as we go, since annotating is slow
+		err = hex.WriteGameRecords(*outp, annotated)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	names := make([]string, 0)
+	for name := range totals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Println("totals:")
+	for _, name := range names {
+		fmt.Printf("  %s: %s\n", name, totals[name])
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+// Plays complete games between two players to see which is stronger.
+// With --sprt, keeps playing until a sequential probability ratio test
+// decides whether player1 is stronger, which is a good way to gate a
+// change to a player.
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"lacker.info/hex"
+)
+
+func main() {
+	hex.Seed()
+
+	// Usage:
+	//   go run arena.go [--games 10] [--openings boards.jsonl] \
+	//     [--records arena.jsonl] player1 player2
+	//   go run arena.go --sprt [--elo0 0] [--elo1 20] [--alpha 0.05] \
+	//     [--beta 0.05] [--games maxGames] newPlayer oldPlayer
+
+	var gamesp = flag.Int("games", 10, "number of games to play")
+	var alternatep = flag.Bool("alternate", true,
+		"swap colors every game. otherwise player1 is always Black")
+	var openingsp = flag.String("openings", "",
+		"file of boards in json, one per line, to start games from")
+	var recordsp = flag.String("records", "arena.jsonl",
+		"file to append game records to")
+	var sprtp = flag.Bool("sprt", false,
+		"play until an SPRT decides. --games is then a maximum, default none")
+	var elo0p = flag.Float64("elo0", 0, "sprt: elo gap for H0")
+	var elo1p = flag.Float64("elo1", 20, "sprt: elo gap for H1")
+	var alphap = flag.Float64("alpha", 0.05, "sprt: false positive rate")
+	var betap = flag.Float64("beta", 0.05, "sprt: false negative rate")
+
+	flag.Parse()
+	args := flag.Args()
+	if len(args) != 2 {
+		log.Fatal("usage: go run arena.go [flags] player1 player2")
+	}
+
+	match := hex.Match{
+		Player1: args[0],
+		Player2: args[1],
+		Games: *gamesp,
+		Alternate: *alternatep,
+		RecordPath: *recordsp,
+	}
+	if *sprtp {
+		match.SPRT = &hex.SPRT{
+			Elo0: *elo0p,
+			Elo1: *elo1p,
+			Alpha: *alphap,
+			Beta: *betap,
+		}
+		gamesSet := false
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "games" {
+				gamesSet = true
+			}
+		})
+		if !gamesSet {
+			match.Games = 0
+		}
+	}
+	if *openingsp != "" {
+		openings, err := hex.ReadNaiveBoards(*openingsp)
+		if err != nil {
+			log.Fatal(err)
+		}
+		match.Openings = openings
+	}
+
+	result, err := match.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(result.Report())
+}
@@ -0,0 +1,86 @@
+package main
+
+// Builds an opening book, by importing game records or by expanding the
+// most promising lines with a search. The book is saved after every
+// expansion, so this can be stopped at any time and started again.
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"lacker.info/hex"
+)
+
+func main() {
+	hex.Seed()
+
+	// Usage:
+	//   go run build_book.go [--book book.json] --import games.jsonl
+	//   go run build_book.go [--book book.json] [--analyzer mcts20] \
+	//     [--maxply 6] [--top 5] --expand 100
+	// Then play from it with a spec like book:file=book.json,then=mcts5
+
+	var bookp = flag.String("book", "book.json", "the book to build")
+	var importp = flag.String("import", "",
+		"file of game records to add to the book")
+	var expandp = flag.Int("expand", 0, "number of positions to analyze")
+	var analyzerp = flag.String("analyzer", "mcts20",
+		"the player that analyzes new positions")
+	var maxplyp = flag.Int("maxply", 6,
+		"the most moves into the game that the book goes")
+	var topp = flag.Int("top", 5, "moves to keep from each analysis")
+
+	flag.Parse()
+	if *importp == "" && *expandp == 0 {
+		log.Fatal("one of --import and --expand is needed")
+	}
+
+	book := hex.NewOpeningBook(*maxplyp)
+	_, err := os.Stat(*bookp)
+	if err == nil {
+		book, err = hex.ReadOpeningBook(*bookp)
+		if err != nil {
+			log.Fatal(err)
+		}
+		book.MaxPly = *maxplyp
+		log.Printf("loaded %d positions from %s", len(book.Positions), *bookp)
+	}
+
+	if *importp != "" {
+		records, err := hex.ReadGameRecords(*importp)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, record := range records {
+			err = book.Import(record)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		log.Printf("imported %d games", len(records))
+		err = book.Write(*bookp)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *expandp > 0 {
+		analyzer, ok := hex.GetPlayer(*analyzerp).(hex.Analyzer)
+		if !ok {
+			log.Fatalf("%s cannot analyze positions", *analyzerp)
+		}
+		for i := 0; i < *expandp; i++ {
+			if !book.Expand(analyzer, hex.NewNaiveBoard(), *topp) {
+				log.Printf("every line is complete to ply %d", book.MaxPly)
+				break
+			}
+			err = book.Write(*bookp)
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("expansion %d: %d positions", i + 1, len(book.Positions))
+		}
+	}
+	log.Printf("saved %d positions to %s", len(book.Positions), *bookp)
+}
@@ -1,11 +1,13 @@
 package main
 
-// Tests the performance of a hex bot on a particular set of challenges.
+// Tests the performance of hex bots on a suite of puzzles.
 // This is useful as a sort of integration test to efficiently check
 // that overall performance has remained high after some tweak.
 
 import (
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"log"
 
 	"lacker.info/hex"
@@ -14,11 +16,54 @@ import (
 func main() {
 	hex.Seed()
 
+	// Usage:
+	//   go run gauntlet.go [--suite default] [--puzzles dir] [--trials 5] \
+	//     [--parallel 0] [--json results.json] player1 [player2 ...]
+
+	var suitep = flag.String("suite", "default",
+		"the puzzles to run, by name or tag. \"all\" runs every puzzle")
+	var puzzlesp = flag.String("puzzles", "",
+		"a directory of .puzzle files to add to the built-in puzzles")
+	var trialsp = flag.Int("trials", 5,
+		"how many times each player tries each puzzle")
+	var parallelp = flag.Int("parallel", 0,
+		"how many trials to run at once. 0 means one per cpu")
+	var jsonp = flag.String("json", "",
+		"also write the results as json to this file")
+
 	flag.Parse()
 	args := flag.Args()
-	if len(args) > 1 {
-		log.Fatal("expected at most 1 arg to gauntlet")
+	if len(args) < 1 {
+		log.Fatal("usage: go run gauntlet.go [flags] player1 [player2 ...]")
+	}
+
+	if *puzzlesp != "" {
+		err := hex.AddPuzzleDir(*puzzlesp)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	suite := hex.PuzzleNames(*suitep)
+	if len(suite) == 0 {
+		log.Fatalf("no puzzle has the name or tag %s", *suitep)
 	}
 
-	panic("TODO: implement")
+	g := hex.Gauntlet{
+		Players: args,
+		Puzzles: suite,
+		Trials: *trialsp,
+		Parallel: *parallelp,
+	}
+	result, err := g.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(result.Report())
+	if *jsonp != "" {
+		err = ioutil.WriteFile(*jsonp, []byte(hex.ToJSON(result)), 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 }
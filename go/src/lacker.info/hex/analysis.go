@@ -0,0 +1,90 @@
+package hex
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+An Analysis is a player's explanation of a move, listing the candidate
+moves it considered along with how good it thinks each one is.
+Win rates are all from the point of view of the player to move.
+*/
+
+type MoveAnalysis struct {
+	Move NaiveSpot
+
+	// How many playouts went through this move
+	Playouts int
+
+	// The player's overall estimate of the win rate for this move
+	WinRate float64
+
+	// The estimate from rave statistics alone
+	RaveWinRate float64
+
+	// The expected line of play, starting with Move
+	PrincipalVariation []NaiveSpot
+}
+
+type Analysis struct {
+	// The move the player would make
+	Move NaiveSpot
+	WinRate float64
+	Playouts int
+
+	// Sorted with the most promising moves first
+	Moves []MoveAnalysis
+}
+
+// An Analyzer is a Player that can explain its thinking.
+type Analyzer interface {
+	Player
+	Analyze(b Board) Analysis
+}
+
+// Sorts the moves by win rate, best first.
+func (a *Analysis) Sort() {
+	sort.SliceStable(a.Moves, func(i, j int) bool {
+		return a.Moves[i].WinRate > a.Moves[j].WinRate
+	})
+}
+
+func (m MoveAnalysis) String() string {
+	return fmt.Sprintf("%s: P:%d EV:%.3f RAVE:%.3f PV:%v",
+		m.Move, m.Playouts, m.WinRate, m.RaveWinRate, m.PrincipalVariation)
+}
+
+// A copy of the analysis with only the n most promising moves. Zero or
+// less keeps all of them.
+func (a Analysis) Top(n int) Analysis {
+	if n > 0 && n < len(a.Moves) {
+		a.Moves = a.Moves[:n]
+	}
+	return a
+}
+
+func notations(spots []NaiveSpot) string {
+	words := make([]string, len(spots))
+	for i, spot := range spots {
+		words[i] = spot.Notation()
+	}
+	return strings.Join(words, " ")
+}
+
+// A table of the candidate moves, in standard notation.
+func (a Analysis) Report() string {
+	lines := []string{
+		fmt.Sprintf("best move %s, win rate %.3f after %d playouts",
+			a.Move.Notation(), a.WinRate, a.Playouts),
+		fmt.Sprintf("%-5s %8s %7s %7s  %s", "move", "visits", "win", "rave",
+			"principal variation"),
+	}
+	for _, m := range a.Moves {
+		lines = append(lines, fmt.Sprintf("%-5s %8d %7.3f %7.3f  %s",
+			m.Move.Notation(), m.Playouts, m.WinRate, m.RaveWinRate,
+			notations(m.PrincipalVariation)))
+	}
+	return strings.Join(lines, "\n")
+}
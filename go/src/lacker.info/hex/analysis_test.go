@@ -0,0 +1,17 @@
+package hex
+
+import (
+	"testing"
+)
+
+func TestAnalysisTop(t *testing.T) {
+	a := analysisOf(0.9, 0.5, 0.1)
+	if len(a.Top(2).Moves) != 2 || a.Top(2).Moves[1].WinRate != 0.5 {
+		t.Fatalf("bad top 2: %v", a.Top(2).Moves)
+	}
+	for _, n := range []int{0, -1, 5} {
+		if len(a.Top(n).Moves) != 3 {
+			t.Fatalf("Top(%d) should keep every move", n)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package hex
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+An Annotator goes through a finished game with a strong player and
+judges every move by how much it changed the win rate, flagging the
+big drops as blunders.
+*/
+
+type MoveAnnotation struct {
+	// The analyzer's win rate for the player who moved, before and after
+	// the move
+	WinRateBefore float64
+	WinRateAfter float64
+
+	// What the analyzer would have played instead
+	BestMove NaiveSpot
+
+	Blunder bool
+}
+
+// How much the move cost the player who made it.
+func (a MoveAnnotation) Loss() float64 {
+	return a.WinRateBefore - a.WinRateAfter
+}
+
+type Annotator struct {
+	// The player type used to judge positions. It must be an Analyzer.
+	Analyzer string
+
+	// A move is a blunder if it drops the win rate by at least this
+	Threshold float64
+}
+
+// Judges every move of the game, returning a copy of the record with
+// Annotations filled in.
+func (a *Annotator) Annotate(record GameRecord) (GameRecord, error) {
+	player, err := LookupPlayer(a.Analyzer)
+	if err != nil {
+		return record, err
+	}
+	analyzer, ok := player.(Analyzer)
+	if !ok {
+		return record, fmt.Errorf("%s cannot analyze positions", a.Analyzer)
+	}
+
+	record.Annotations = make([]MoveAnnotation, len(record.Moves))
+	if len(record.Moves) == 0 {
+		return record, nil
+	}
+
+	// The analysis of each position before each move, and of the
+	// position after the last move. Once someone has won, the win rate
+	// is certain.
+	analyses := make([]Analysis, len(record.Moves) + 1)
+	for ply := range analyses {
+		board, err := record.BoardAtPly(ply)
+		if err != nil {
+			return record, err
+		}
+		winner := board.Winner()
+		if winner == Empty {
+			analyses[ply] = analyzer.Analyze(board)
+		} else if winner == board.ToMove {
+			analyses[ply] = Analysis{WinRate: 1.0}
+		} else {
+			analyses[ply] = Analysis{WinRate: 0.0}
+		}
+	}
+
+	for ply := range record.Moves {
+		annotation := MoveAnnotation{
+			WinRateBefore: analyses[ply].WinRate,
+			BestMove: analyses[ply].Move,
+			WinRateAfter: 1.0 - analyses[ply + 1].WinRate,
+		}
+		annotation.Blunder = annotation.Loss() >= a.Threshold
+		record.Annotations[ply] = annotation
+	}
+	return record, nil
+}
+
+// The blunders and total win rate lost by one player in one game.
+type PlayerSummary struct {
+	Moves int
+	Blunders int
+	TotalLoss float64
+}
+
+func (s *PlayerSummary) Add(other PlayerSummary) {
+	s.Moves += other.Moves
+	s.Blunders += other.Blunders
+	s.TotalLoss += other.TotalLoss
+}
+
+// The average win rate lost per move.
+func (s PlayerSummary) AverageLoss() float64 {
+	if s.Moves == 0 {
+		return 0.0
+	}
+	return s.TotalLoss / float64(s.Moves)
+}
+
+func (s PlayerSummary) String() string {
+	return fmt.Sprintf("%d blunders in %d moves, average loss %.3f",
+		s.Blunders, s.Moves, s.AverageLoss())
+}
+
+// Summarizes an annotated game for each player, by name.
+func SummarizeGame(record GameRecord) map[string]*PlayerSummary {
+	answer := map[string]*PlayerSummary{
+		record.Black: &PlayerSummary{},
+		record.White: &PlayerSummary{},
+	}
+	for ply, annotation := range record.Annotations {
+		summary := answer[record.PlayerForPly(ply)]
+		summary.Moves++
+		if annotation.Loss() > 0 {
+			summary.TotalLoss += annotation.Loss()
+		}
+		if annotation.Blunder {
+			summary.Blunders++
+		}
+	}
+	return answer
+}
+
+// Describes an annotated game, listing each blunder.
+func GameReport(record GameRecord) string {
+	lines := []string{fmt.Sprintf("%s (Black) vs %s (White): %s wins in %d moves",
+		record.Black, record.White, record.Winner.Name(), len(record.Moves))}
+	summaries := SummarizeGame(record)
+	lines = append(lines, fmt.Sprintf("  %s: %s", record.Black,
+		summaries[record.Black]))
+	if record.White != record.Black {
+		lines = append(lines, fmt.Sprintf("  %s: %s", record.White,
+			summaries[record.White]))
+	}
+	for ply, annotation := range record.Annotations {
+		if !annotation.Blunder {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf(
+			"  ply %d: %s played %s, win rate %.3f -> %.3f. better was %s",
+			ply, record.PlayerForPly(ply), record.Moves[ply].Notation(),
+			annotation.WinRateBefore, annotation.WinRateAfter,
+			annotation.BestMove.Notation()))
+	}
+	return strings.Join(lines, "\n")
+}
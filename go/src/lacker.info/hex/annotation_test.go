@@ -0,0 +1,74 @@
+package hex
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnnotate(t *testing.T) {
+	// Black has a winning move at (10, 0) and plays elsewhere
+	board := PuzzleMap["onePly"].Board
+	record := GameRecord{
+		Black: "careless",
+		White: "lucky",
+		Opening: board,
+		Moves: []NaiveSpot{MakeNaiveSpot(0, 5), MakeNaiveSpot(10, 0)},
+		Winner: White,
+	}
+	annotator := Annotator{Analyzer: "mcts:seconds=0.2,quiet=true",
+		Threshold: 0.3}
+	annotated, err := annotator.Annotate(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(annotated.Annotations) != 2 {
+		t.Fatalf("expected 2 annotations but got %d",
+			len(annotated.Annotations))
+	}
+	first := annotated.Annotations[0]
+	if !first.Blunder || first.BestMove != MakeNaiveSpot(10, 0) {
+		t.Fatalf("the first move should be a blunder: %+v", first)
+	}
+	if annotated.Annotations[1].Blunder {
+		t.Fatalf("the winning move should not be a blunder")
+	}
+
+	summaries := SummarizeGame(annotated)
+	if summaries["careless"].Blunders != 1 || summaries["lucky"].Blunders != 0 {
+		t.Fatalf("bad summaries: %v %v", summaries["careless"],
+			summaries["lucky"])
+	}
+	if !strings.Contains(GameReport(annotated), "ply 0: careless played f1") {
+		t.Fatalf("bad report:\n%s", GameReport(annotated))
+	}
+}
+
+func TestAnnotateResignedGame(t *testing.T) {
+	// Black misses the winning move at (10, 0) and resigns, so the game
+	// ends without White ever winning on the board
+	record := GameRecord{
+		Black: "careless",
+		White: "lucky",
+		Opening: PuzzleMap["onePly"].Board,
+		Moves: []NaiveSpot{MakeNaiveSpot(0, 5)},
+		Winner: White,
+	}
+	annotator := Annotator{Analyzer: "mcts:seconds=0.2,quiet=true",
+		Threshold: 0.3}
+	annotated, err := annotator.Annotate(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := annotated.Annotations[0]
+	if last.WinRateAfter > 0.5 || !last.Blunder {
+		t.Fatalf("the losing last move should be a blunder: %+v", last)
+	}
+}
+
+func TestAnnotateNeedsAnalyzer(t *testing.T) {
+	annotator := Annotator{Analyzer: "random", Threshold: 0.3}
+	_, err := annotator.Annotate(GameRecord{})
+	if err == nil {
+		t.Fatalf("random cannot analyze so annotating should fail")
+	}
+}
@@ -2,6 +2,7 @@ package hex
 
 import (
 	"fmt"
+	"math/rand"
 )
 
 /*
@@ -74,4 +75,7 @@ type Board interface {
 
 	// Plays out the game randomly and tells you who won.
 	Playout() Color
+
+	// Like Playout, with a particular source of randomness.
+	PlayoutWith(r *rand.Rand) Color
 }
@@ -0,0 +1,172 @@
+package hex
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+/*
+Elo ratings are fit to game results with the Bradley-Terry model,
+which is what BayesElo does as well. Each player gets a strength gamma
+and is expected to beat another player with probability
+gamma1 / (gamma1 + gamma2). A rating is 400 * log10(gamma).
+
+Like BayesElo, every player gets a prior of one win and one loss
+against a virtual player rated zero, so that a player who wins or
+loses every game still gets a finite rating.
+*/
+
+type Rating struct {
+	Name string
+	Elo float64
+
+	// The 95% confidence interval is Elo plus or minus this
+	Error float64
+
+	Games int
+	Wins int
+}
+
+type Ratings []Rating
+
+// The number of games played and won between each pair of players.
+type eloTable struct {
+	names []string
+	index map[string]int
+	games [][]float64
+	wins [][]float64
+}
+
+func newEloTable(records []GameRecord) *eloTable {
+	table := &eloTable{index: make(map[string]int)}
+	for _, record := range records {
+		table.add(record.Black)
+		table.add(record.White)
+	}
+	n := len(table.names)
+	table.games = make([][]float64, n)
+	table.wins = make([][]float64, n)
+	for i := range table.names {
+		table.games[i] = make([]float64, n)
+		table.wins[i] = make([]float64, n)
+	}
+	for _, record := range records {
+		black := table.index[record.Black]
+		white := table.index[record.White]
+		table.games[black][white]++
+		table.games[white][black]++
+		switch record.Winner {
+		case Black:
+			table.wins[black][white]++
+		case White:
+			table.wins[white][black]++
+		}
+	}
+	return table
+}
+
+func (table *eloTable) add(name string) {
+	_, ok := table.index[name]
+	if !ok {
+		table.index[name] = len(table.names)
+		table.names = append(table.names, name)
+	}
+}
+
+// Fits ratings to the game records. If anchor is the name of a player,
+// that player is rated zero. Otherwise the average rating is zero.
+// The ratings are sorted best first.
+func ComputeRatings(records []GameRecord, anchor string) Ratings {
+	table := newEloTable(records)
+	n := len(table.names)
+
+	// Fit the strengths with the minorization-maximization algorithm
+	// for Bradley-Terry models. The virtual opponent has gamma 1.
+	gamma := make([]float64, n)
+	for i := range gamma {
+		gamma[i] = 1.0
+	}
+	for iteration := 0; iteration < 10000; iteration++ {
+		maxChange := 0.0
+		for i := 0; i < n; i++ {
+			wins := 1.0
+			denominator := 2.0 / (gamma[i] + 1.0)
+			for j := 0; j < n; j++ {
+				wins += table.wins[i][j]
+				denominator += table.games[i][j] / (gamma[i] + gamma[j])
+			}
+			newGamma := wins / denominator
+			maxChange = math.Max(maxChange,
+				math.Abs(math.Log(newGamma / gamma[i])))
+			gamma[i] = newGamma
+		}
+		if maxChange < 1e-9 {
+			break
+		}
+	}
+
+	// The standard error of each log-strength comes from the Fisher
+	// information of its games.
+	scale := 400.0 / math.Ln10
+	ratings := make(Ratings, n)
+	for i, name := range table.names {
+		p := gamma[i] / (gamma[i] + 1.0)
+		information := 2.0 * p * (1.0 - p)
+		games := 0.0
+		wins := 0.0
+		for j := 0; j < n; j++ {
+			p = gamma[i] / (gamma[i] + gamma[j])
+			information += table.games[i][j] * p * (1.0 - p)
+			games += table.games[i][j]
+			wins += table.wins[i][j]
+		}
+		ratings[i] = Rating{
+			Name: name,
+			Elo: scale * math.Log(gamma[i]),
+			Error: Z95 * scale / math.Sqrt(information),
+			Games: int(games),
+			Wins: int(wins),
+		}
+	}
+
+	// Shift so that the anchor, or the average, is zero
+	offset := 0.0
+	anchorIndex, ok := table.index[anchor]
+	if ok {
+		offset = ratings[anchorIndex].Elo
+	} else if n > 0 {
+		for _, rating := range ratings {
+			offset += rating.Elo / float64(n)
+		}
+	}
+	for i := range ratings {
+		ratings[i].Elo -= offset
+	}
+
+	sort.SliceStable(ratings, func(i, j int) bool {
+		return ratings[i].Elo > ratings[j].Elo
+	})
+	return ratings
+}
+
+func (ratings Ratings) Get(name string) (Rating, bool) {
+	for _, rating := range ratings {
+		if rating.Name == name {
+			return rating, true
+		}
+	}
+	return Rating{}, false
+}
+
+func (ratings Ratings) String() string {
+	lines := []string{
+		fmt.Sprintf("%-20s %7s %7s %7s", "player", "elo", "+/-", "games"),
+	}
+	for _, r := range ratings {
+		lines = append(lines, fmt.Sprintf("%-20s %7.0f %7.0f %3d/%-3d",
+			r.Name, r.Elo, r.Error, r.Wins, r.Games))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,56 @@
+package hex
+
+import (
+	"testing"
+)
+
+func makeRecords(winner string, loser string, wins int,
+	losses int) []GameRecord {
+	answer := make([]GameRecord, 0)
+	for i := 0; i < wins; i++ {
+		answer = append(answer,
+			GameRecord{Black: winner, White: loser, Winner: Black})
+	}
+	for i := 0; i < losses; i++ {
+		answer = append(answer,
+			GameRecord{Black: winner, White: loser, Winner: White})
+	}
+	return answer
+}
+
+func TestComputeRatings(t *testing.T) {
+	records := makeRecords("strong", "weak", 30, 10)
+	records = append(records, makeRecords("weak", "weaker", 20, 20)...)
+	ratings := ComputeRatings(records, "weak")
+
+	if ratings[0].Name != "strong" {
+		t.Fatalf("strong should be rated first:\n%s", ratings)
+	}
+	weak, _ := ratings.Get("weak")
+	if weak.Elo != 0.0 {
+		t.Fatalf("the anchor should be rated zero:\n%s", ratings)
+	}
+	strong, _ := ratings.Get("strong")
+	if strong.Elo < 100 || strong.Elo > 250 {
+		t.Fatalf("winning 75%% is worth about 190 elo:\n%s", ratings)
+	}
+	weaker, _ := ratings.Get("weaker")
+	if weaker.Error <= 0.0 || weaker.Games != 40 || weaker.Wins != 20 {
+		t.Fatalf("bad record for weaker:\n%s", ratings)
+	}
+}
+
+func TestComputeRatingsMoreGamesShrinkError(t *testing.T) {
+	few := ComputeRatings(makeRecords("a", "b", 6, 4), "")
+	many := ComputeRatings(makeRecords("a", "b", 60, 40), "")
+	if many[0].Error >= few[0].Error {
+		t.Fatalf("more games should give tighter error bars")
+	}
+}
+
+func TestComputeRatingsPerfectScore(t *testing.T) {
+	ratings := ComputeRatings(makeRecords("a", "b", 10, 0), "")
+	if ratings[0].Elo > 1000 {
+		t.Fatalf("the prior should keep perfect scores finite:\n%s", ratings)
+	}
+}
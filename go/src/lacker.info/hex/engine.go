@@ -0,0 +1,260 @@
+package hex
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+)
+
+/*
+The Engine is a long-lived process that answers move requests, so
+that callers don't need to start a new program for every move.
+
+The protocol is newline-delimited json. Each line is one
+EngineRequest, and the engine writes exactly one final EngineResponse
+line for it. Streaming requests also get progress lines before the
+final one. Players are created the first time their type is requested
+and are kept around for later requests, so any state they keep
+survives between moves.
+
+A player asked to ponder keeps thinking after it answers, until the
+engine gets another request. Pondering can also be stopped with a
+request that just has StopPondering set, for example when the game is
+over.
+*/
+
+type EngineRequest struct {
+	// Echoed back in the response so callers can match them up
+	Id string
+
+	// The player type, as passed to GetPlayer
+	Player string
+
+	Board *NaiveBoard
+
+	// How long the player may think. Zero means the default for the
+	// player type.
+	Seconds float64
+
+	// The game clock, for players to plan their own time from. It
+	// overrides Seconds.
+	Clock *GameClock
+
+	// Whether to include an analysis, for players that can provide one
+	Analysis bool
+
+	// Whether to stream progress while the player thinks, for players
+	// that can report it
+	Stream bool
+
+	// Whether the player should think on the position after its move
+	// until the next request, for players that can ponder
+	Ponder bool
+
+	// Just stops any pondering. Nothing else in the request is needed.
+	StopPondering bool
+}
+
+type EngineResponse struct {
+	Id string
+	Move *NaiveSpot `json:",omitempty"`
+	WinRate float64
+	Analysis *Analysis `json:",omitempty"`
+	Error string `json:",omitempty"`
+
+	// Set on the progress lines that come before the final response to a
+	// streaming request. Those lines have no move.
+	Progress *SearchProgress `json:",omitempty"`
+}
+
+type Engine struct {
+	// Requests are handled one at a time since players aren't safe to
+	// use concurrently.
+	mutex sync.Mutex
+
+	// Keyed by player type
+	players map[string]Player
+
+	// The limits each player type starts with, so that a request
+	// without a budget doesn't get the last request's
+	limits map[string]SearchLimits
+}
+
+func NewEngine() *Engine {
+	return &Engine{
+		players: make(map[string]Player),
+		limits: make(map[string]SearchLimits),
+	}
+}
+
+// Gets the player for a player type, creating it if needed.
+func (e *Engine) getPlayer(playerType string) (Player, error) {
+	player, ok := e.players[playerType]
+	if ok {
+		return player, nil
+	}
+	player, err := LookupPlayer(playerType)
+	if err != nil {
+		return nil, err
+	}
+	e.players[playerType] = player
+	limited, ok := player.(LimitedPlayer)
+	if ok {
+		e.limits[playerType] = limited.Limits()
+	}
+	return player, nil
+}
+
+func errorResponse(id string, err error) EngineResponse {
+	return EngineResponse{Id: id, Error: err.Error()}
+}
+
+// Stops every player that is pondering.
+func (e *Engine) StopPondering() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.stopPondering()
+}
+
+func (e *Engine) stopPondering() {
+	for _, player := range e.players {
+		StopPondering(player)
+	}
+}
+
+// Plays a move for the request. The observer, if any, gets told about
+// progress while the player thinks.
+func (e *Engine) Handle(request EngineRequest,
+	observer SearchObserver) EngineResponse {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// Pondering players would be using the time and changing their state
+	e.stopPondering()
+	if request.StopPondering {
+		return EngineResponse{Id: request.Id}
+	}
+
+	if request.Board == nil {
+		return errorResponse(request.Id, fmt.Errorf("no board in request"))
+	}
+	err := request.Board.Validate()
+	if err != nil {
+		return errorResponse(request.Id, err)
+	}
+	if len(request.Board.PossibleMoves()) == 0 {
+		return errorResponse(request.Id, fmt.Errorf("the board is full"))
+	}
+	player, err := e.getPlayer(request.Player)
+	if err != nil {
+		return errorResponse(request.Id, err)
+	}
+
+	// Players that don't think, like random, just ignore the budget.
+	limited, ok := player.(LimitedPlayer)
+	if ok {
+		limits := e.limits[request.Player]
+		if request.Seconds > 0 {
+			limits.Seconds = request.Seconds
+		}
+		limited.SetLimits(limits)
+	}
+	if request.Clock != nil {
+		UseClock(player, *request.Clock, request.Board)
+	}
+
+	observable, ok := player.(ObservablePlayer)
+	if ok {
+		observable.SetObserver(observer)
+		defer observable.SetObserver(nil)
+	}
+
+	response := EngineResponse{Id: request.Id}
+	analyzer, ok := player.(Analyzer)
+	if request.Analysis && ok {
+		analysis := analyzer.Analyze(request.Board)
+		response.Move = &analysis.Move
+		response.WinRate = analysis.WinRate
+		response.Analysis = &analysis
+	} else {
+		move, winRate := player.Play(request.Board)
+		response.Move = &move
+		response.WinRate = winRate
+	}
+
+	ponderer, ok := player.(Ponderer)
+	if request.Ponder && ok {
+		after := request.Board.ToNaiveBoard()
+		after.MakeMove(*response.Move)
+		ponderer.StartPondering(after)
+	}
+	return response
+}
+
+// Reads requests from r and writes responses to w until r runs out.
+func (e *Engine) Serve(r io.Reader, w io.Writer) error {
+	// Nobody is left to ask for the next move
+	defer e.StopPondering()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64 * 1024), 1024 * 1024)
+	encoder := json.NewEncoder(w)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var request EngineRequest
+		var response EngineResponse
+		err := json.Unmarshal(line, &request)
+		if err != nil {
+			response = errorResponse(request.Id, err)
+		} else {
+			var observer SearchObserver
+			if request.Stream {
+				id := request.Id
+				observer = SearchObserverFunc(func(progress SearchProgress) {
+					err := encoder.Encode(
+						EngineResponse{Id: id, Progress: &progress})
+					if err != nil {
+						log.Printf("could not write progress: %s", err)
+					}
+				})
+			}
+			response = e.Handle(request, observer)
+		}
+		err = encoder.Encode(response)
+		if err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Serves each connection on a local socket. The network is typically
+// "unix", with the address being a socket path.
+func (e *Engine) ListenAndServe(network string, address string) error {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	log.Printf("engine listening on %s %s", network, address)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			err := e.Serve(conn, conn)
+			if err != nil {
+				log.Printf("engine connection failed: %s", err)
+			}
+		}()
+	}
+}
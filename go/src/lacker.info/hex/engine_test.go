@@ -0,0 +1,147 @@
+package hex
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEngineServe(t *testing.T) {
+	board := ToJSON(PuzzleMap["onePly"].Board)
+	input := strings.Join([]string{
+		`{"Id":"1","Player":"random","Board":` + board + `}`,
+		`{"Id":"2","Player":"nonsense","Board":` + board + `}`,
+		`{"Id":"3","Player":"random"}`,
+		`not json`,
+		`{"Id":"5","Player":"random","Board":` + board + `,"Seconds":2}`,
+	}, "\n")
+
+	var output bytes.Buffer
+	engine := NewEngine()
+	err := engine.Serve(strings.NewReader(input), &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 responses but got %d", len(lines))
+	}
+	responses := make([]EngineResponse, len(lines))
+	for i, line := range lines {
+		err = json.Unmarshal([]byte(line), &responses[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if responses[0].Id != "1" || responses[0].Move == nil ||
+		responses[0].Error != "" {
+		t.Fatalf("bad response to a valid request: %s", lines[0])
+	}
+	if PuzzleMap["onePly"].Board.Get(*responses[0].Move) != Empty {
+		t.Fatalf("random played on an occupied spot")
+	}
+	if responses[1].Error == "" || responses[2].Error == "" ||
+		responses[3].Error == "" {
+		t.Fatalf("expected errors for bad requests")
+	}
+	if responses[4].Error != "" {
+		t.Fatalf("players without a time budget should ignore Seconds")
+	}
+	if len(engine.players) != 1 {
+		t.Fatalf("the engine should keep one player around")
+	}
+}
+
+func TestEngineStream(t *testing.T) {
+	oldInterval := ProgressInterval
+	ProgressInterval = 0
+	defer func() { ProgressInterval = oldInterval }()
+
+	board := ToJSON(PuzzleMap["onePly"].Board)
+	input := `{"Id":"s","Player":"sr1","Seconds":0.05,"Stream":true,"Board":` +
+		board + `}`
+
+	var output bytes.Buffer
+	engine := NewEngine()
+	err := engine.Serve(strings.NewReader(input), &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("expected progress before the move but got %d lines",
+			len(lines))
+	}
+	for i, line := range lines {
+		var response EngineResponse
+		err = json.Unmarshal([]byte(line), &response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		last := i == len(lines) - 1
+		if last != (response.Progress == nil) || last != (response.Move != nil) {
+			t.Fatalf("only the last line should have a move: %s", line)
+		}
+	}
+}
+
+func TestEnginePonder(t *testing.T) {
+	engine := NewEngine()
+	spec := "mcts:seconds=0.05,quiet=true"
+	board := NewNaiveBoard()
+	response := engine.Handle(
+		EngineRequest{Player: spec, Board: board, Ponder: true}, nil)
+	mcts := engine.players[spec].(*MonteCarloTreeSearch)
+	if mcts.stopPondering == nil {
+		t.Fatalf("the player should be pondering")
+	}
+
+	board.MakeMove(*response.Move)
+	if mcts.trees[0].Board.Get(*response.Move) == Empty {
+		t.Fatalf("the player should ponder on the position after its move")
+	}
+
+	response = engine.Handle(EngineRequest{Id: "stop", StopPondering: true},
+		nil)
+	if response.Error != "" || response.Move != nil ||
+		mcts.stopPondering != nil {
+		t.Fatalf("bad response to stopping: %+v", response)
+	}
+}
+
+func TestEngineResetsLimits(t *testing.T) {
+	engine := NewEngine()
+	spec := "mcts:seconds=0.02,quiet=true"
+	board := NewNaiveBoard()
+	engine.Handle(EngineRequest{Player: spec, Board: board, Seconds: 0.05}, nil)
+	mcts := engine.players[spec].(*MonteCarloTreeSearch)
+	if mcts.Seconds != 0.05 {
+		t.Fatalf("the request should set the budget, not %.2f", mcts.Seconds)
+	}
+
+	// The next request gives no budget, so it gets the spec's
+	engine.Handle(EngineRequest{Player: spec, Board: board}, nil)
+	if mcts.Seconds != 0.02 {
+		t.Fatalf("the budget should be back to 0.02, not %.2f", mcts.Seconds)
+	}
+}
+
+func TestEngineRejectsBadSpots(t *testing.T) {
+	var spot NaiveSpot
+	err := json.Unmarshal([]byte(`{"Row":3,"Col":11}`), &spot)
+	if err == nil {
+		t.Fatalf("(3, 11) is off the board")
+	}
+	err = json.Unmarshal([]byte(`{"Row":-1,"Col":0}`), &spot)
+	if err == nil {
+		t.Fatalf("(-1, 0) is off the board")
+	}
+	err = json.Unmarshal([]byte(`{"Row":10,"Col":10}`), &spot)
+	if err != nil || spot != MakeNaiveSpot(10, 10) {
+		t.Fatalf("(10, 10) should parse, not %s: %v", spot, err)
+	}
+}
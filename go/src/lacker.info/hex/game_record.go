@@ -0,0 +1,132 @@
+package hex
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+/*
+A GameRecord is the history of one complete game.
+Records are stored as newline-delimited json, one game per line, so
+that a long run can append each game as it finishes.
+*/
+
+type GameRecord struct {
+	// The player names. These are the player types, as passed to
+	// GetPlayer, unless something like a tournament renamed them.
+	Black string
+	White string
+
+	// The position the game started from. Nil means the empty board.
+	Opening *NaiveBoard `json:",omitempty"`
+
+	Moves []NaiveSpot
+
+	// What each player estimated its win rate to be when it moved.
+	// Parallel to Moves.
+	WinRates []float64
+
+	Winner Color
+
+	// An Annotator's judgment of each move, if the game was annotated.
+	// Parallel to Moves.
+	Annotations []MoveAnnotation `json:",omitempty"`
+
+	// Which game of its pairing this was, counting from zero, for games
+	// played in a tournament. Nil for other games.
+	Game *int `json:",omitempty"`
+}
+
+// A copy of the position the game started from.
+func (r *GameRecord) StartingBoard() *NaiveBoard {
+	if r.Opening == nil {
+		return NewNaiveBoard()
+	}
+	return r.Opening.ToNaiveBoard()
+}
+
+// The position after the first ply moves of the game.
+func (r *GameRecord) BoardAtPly(ply int) (*NaiveBoard, error) {
+	if ply < 0 || ply > len(r.Moves) {
+		return nil, fmt.Errorf("ply %d is not in a game of %d moves",
+			ply, len(r.Moves))
+	}
+	board := r.StartingBoard()
+	for _, move := range r.Moves[:ply] {
+		if move.IsNotASpot() || board.Get(move) != Empty {
+			return nil, fmt.Errorf("bad move in record: %s", move)
+		}
+		board.MakeMove(move)
+	}
+	return board, nil
+}
+
+// The type of the player who made the move at the given ply.
+func (r *GameRecord) PlayerForPly(ply int) string {
+	if r.StartingBoard().ToMove == Black {
+		if ply % 2 == 0 {
+			return r.Black
+		}
+		return r.White
+	}
+	if ply % 2 == 0 {
+		return r.White
+	}
+	return r.Black
+}
+
+// Appends a record to a file, creating the file if needed.
+func AppendGameRecord(path string, record GameRecord) error {
+	f, err := os.OpenFile(path, os.O_APPEND | os.O_CREATE | os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f, ToJSON(record))
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Writes records to a file, replacing whatever was there.
+func WriteGameRecords(path string, records []GameRecord) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		_, err = fmt.Fprintln(f, ToJSON(record))
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+func ReadGameRecords(path string) ([]GameRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	answer := make([]GameRecord, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64 * 1024), 16 * 1024 * 1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record GameRecord
+		err = json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, line, err)
+		}
+		answer = append(answer, record)
+	}
+	return answer, scanner.Err()
+}
@@ -0,0 +1,276 @@
+package hex
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+A Gauntlet runs players through a suite of puzzles, several times
+each since most players are random, to efficiently check that overall
+performance has remained high after some tweak.
+*/
+
+type Gauntlet struct {
+	// The player types, as passed to GetPlayer
+	Players []string
+
+	// The puzzle names
+	Puzzles []string
+
+	// How many times each player tries each puzzle
+	Trials int
+
+	// How many trials to run at once. Zero means one per cpu.
+	Parallel int
+}
+
+// The outcome of one player trying one puzzle many times.
+type PuzzleResult struct {
+	Player string
+	Puzzle string
+	Type PuzzleType
+	Trials int
+	Passes int
+
+	// The 95% confidence interval for the pass rate
+	PassRateLow float64
+	PassRateHigh float64
+
+	// The average over passing trials of how long it took before the
+	// player settled on its final answer. Only players that report
+	// their progress can settle before they finish thinking.
+	SecondsToSolve float64
+
+	// The average over all trials
+	Seconds float64
+	WinRate float64
+}
+
+func (r PuzzleResult) PassRate() float64 {
+	return float64(r.Passes) / float64(r.Trials)
+}
+
+type GauntletResult struct {
+	Results []PuzzleResult
+
+	// The total passes and trials for each player
+	Scores map[string]*WinCount
+}
+
+type gauntletTrial struct {
+	player string
+	puzzle string
+}
+
+type trialOutcome struct {
+	passed bool
+	seconds float64
+	secondsToSolve float64
+	winRate float64
+}
+
+// Runs a single trial with a fresh player.
+func runTrial(trial gauntletTrial) (trialOutcome, error) {
+	player, err := LookupPlayer(trial.player)
+	if err != nil {
+		return trialOutcome{}, err
+	}
+	puzzle, err := LookupPuzzle(trial.puzzle)
+	if err != nil {
+		return trialOutcome{}, err
+	}
+
+	// Track when the player last changed its mind
+	start := time.Now()
+	settled := 0.0
+	var lastMove *NaiveSpot
+	observable, ok := player.(ObservablePlayer)
+	if ok {
+		observable.SetObserver(SearchObserverFunc(func(p SearchProgress) {
+			if lastMove == nil || *lastMove != p.BestMove {
+				move := p.BestMove
+				lastMove = &move
+				settled = SecondsSince(start)
+			}
+		}))
+	}
+
+	move, winRate := player.Play(puzzle.Board.ToNaiveBoard())
+	seconds := SecondsSince(start)
+	if lastMove == nil || *lastMove != move {
+		settled = seconds
+	}
+	return trialOutcome{
+		passed: puzzle.IsSolvedBy(move, winRate),
+		seconds: seconds,
+		secondsToSolve: settled,
+		winRate: winRate,
+	}, nil
+}
+
+func (g *Gauntlet) Validate() error {
+	for _, player := range g.Players {
+		_, err := LookupPlayer(player)
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range g.Puzzles {
+		_, err := LookupPuzzle(name)
+		if err != nil {
+			return err
+		}
+	}
+	if g.Trials <= 0 {
+		return fmt.Errorf("a gauntlet needs a positive number of trials")
+	}
+	return nil
+}
+
+func (g *Gauntlet) Run() (*GauntletResult, error) {
+	err := g.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	// Set up a result to collect the trials for each player and puzzle
+	results := make(map[gauntletTrial]*PuzzleResult)
+	trials := make([]gauntletTrial, 0)
+	for _, player := range g.Players {
+		for _, name := range g.Puzzles {
+			trial := gauntletTrial{player, name}
+			results[trial] = &PuzzleResult{
+				Player: player,
+				Puzzle: name,
+				Type: PuzzleMap[name].Type,
+			}
+			for i := 0; i < g.Trials; i++ {
+				trials = append(trials, trial)
+			}
+		}
+	}
+
+	parallel := g.Parallel
+	if parallel <= 0 {
+		parallel = runtime.NumCPU()
+	}
+	queue := make(chan gauntletTrial)
+	var mutex sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for trial := range queue {
+				outcome, err := runTrial(trial)
+
+				mutex.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+				} else {
+					result := results[trial]
+					result.Trials++
+					result.Seconds += outcome.seconds
+					result.WinRate += outcome.winRate
+					if outcome.passed {
+						result.Passes++
+						result.SecondsToSolve += outcome.secondsToSolve
+					}
+					log.Printf("%s on %s: passed=%v, win rate %.3f",
+						trial.player, trial.puzzle, outcome.passed,
+						outcome.winRate)
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, trial := range trials {
+		queue <- trial
+	}
+	close(queue)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	// Turn the sums into averages
+	answer := &GauntletResult{
+		Results: make([]PuzzleResult, 0),
+		Scores: make(map[string]*WinCount),
+	}
+	for _, player := range g.Players {
+		score := &WinCount{}
+		answer.Scores[player] = score
+		for _, name := range g.Puzzles {
+			result := results[gauntletTrial{player, name}]
+			result.Seconds /= float64(result.Trials)
+			result.WinRate /= float64(result.Trials)
+			if result.Passes > 0 {
+				result.SecondsToSolve /= float64(result.Passes)
+			}
+			result.PassRateLow, result.PassRateHigh = WilsonInterval(
+				result.Passes, result.Trials)
+			score.Wins += result.Passes
+			score.Games += result.Trials
+			answer.Results = append(answer.Results, *result)
+		}
+	}
+	return answer, nil
+}
+
+func (r *GauntletResult) Report() string {
+	lines := []string{fmt.Sprintf("%-10s %-14s %-12s %-22s %7s %7s %7s",
+		"player", "puzzle", "type", "pass rate (95% CI)", "solve", "secs",
+		"win")}
+	for _, result := range r.Results {
+		lines = append(lines, fmt.Sprintf(
+			"%-10s %-14s %-12s %3d/%-3d (%3.0f%%-%3.0f%%)    %7.2f %7.2f %7.3f",
+			result.Player, result.Puzzle, result.Type, result.Passes,
+			result.Trials, 100.0 * result.PassRateLow,
+			100.0 * result.PassRateHigh, result.SecondsToSolve,
+			result.Seconds, result.WinRate))
+	}
+	players := make([]string, 0)
+	for player := range r.Scores {
+		players = append(players, player)
+	}
+	sort.Strings(players)
+	for _, player := range players {
+		lines = append(lines, fmt.Sprintf("SCORE %s: %s", player,
+			r.Scores[player]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// The names of the puzzles found by FindPuzzles.
+func PuzzleNames(nameOrTag string) []string {
+	answer := make([]string, 0)
+	for _, puzzle := range FindPuzzles(nameOrTag) {
+		answer = append(answer, puzzle.Name)
+	}
+	return answer
+}
+
+// Runs the player once through the default puzzles and logs the score.
+func RunGauntlet(playerName string) {
+	g := Gauntlet{
+		Players: []string{playerName},
+		Puzzles: PuzzleNames("default"),
+		Trials: 1,
+		Parallel: 1,
+	}
+	result, err := g.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%s", result.Report())
+}
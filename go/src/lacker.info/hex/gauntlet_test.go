@@ -0,0 +1,62 @@
+package hex
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGauntlet(t *testing.T) {
+	g := Gauntlet{
+		Players: []string{"random"},
+		Puzzles: []string{"doomed1", "onePly"},
+		Trials: 3,
+		Parallel: 2,
+	}
+	result, err := g.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Results) != 2 {
+		t.Fatalf("expected a result per puzzle")
+	}
+	doomed := result.Results[0]
+	if doomed.Puzzle != "doomed1" || doomed.Trials != 3 || doomed.Passes != 0 {
+		t.Fatalf("random always guesses 0.5 so it can't pass doomed1")
+	}
+	if doomed.PassRateLow != 0 || doomed.PassRateHigh <= 0 {
+		t.Fatalf("bad confidence interval")
+	}
+	if result.Scores["random"].Games != 6 {
+		t.Fatalf("the score should count every trial")
+	}
+
+	var decoded GauntletResult
+	err = json.Unmarshal([]byte(ToJSON(result)), &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Results[0].Type != DefiniteLoss {
+		t.Fatalf("puzzle types should survive json")
+	}
+}
+
+func TestPuzzleNames(t *testing.T) {
+	names := PuzzleNames("default")
+	if len(names) != 8 || names[0] != "doomed1" {
+		t.Fatalf("bad default puzzles: %v", names)
+	}
+	if len(PuzzleNames("needle")) != 1 {
+		t.Fatalf("puzzles should be found by name")
+	}
+}
+
+func TestGauntletValidate(t *testing.T) {
+	g := Gauntlet{
+		Players: []string{"random"},
+		Puzzles: []string{"nonsense"},
+		Trials: 1,
+	}
+	if g.Validate() == nil {
+		t.Fatalf("unknown puzzles should be rejected")
+	}
+}
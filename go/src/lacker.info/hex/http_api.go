@@ -0,0 +1,384 @@
+package hex
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+/*
+The APIServer exposes the players over HTTP with json requests and
+responses, so that a browser viewer or other local services can query
+them.
+
+Endpoints:
+  POST /position  {"Board": board}
+    Stores a position and returns {"Position": id} to refer to it later.
+    Only the MaxPositions most recently used positions are kept.
+  POST /play      {"Board" or "Position", "Player", "Seconds"}
+    Returns the move the player would make.
+  POST /analyze   {"Board" or "Position", "Player", "Seconds"}
+    Returns the player's analysis and a heatmap of win rates by spot.
+  POST /stream    {"Board" or "Position", "Player", "Seconds"}
+    Like /play, but streams newline-delimited json while the player
+    thinks. Each line is {"Progress": progress} until the last one,
+    which is the move.
+  GET  /puzzle?name=doomed1&player=mcts1&seconds=1
+    Runs a named puzzle and reports whether the player solved it.
+
+Errors come back as {"Error": message} with a non-200 status.
+Requests are handled concurrently, each with its own player, and the
+thinking time for any request is capped at MaxSeconds. Players are
+dropped after each request, so none of them is left pondering.
+*/
+
+type APIServer struct {
+	// The most time any one request may think for
+	MaxSeconds float64
+
+	// The player to use when a request doesn't name one
+	DefaultPlayer string
+
+	// The most positions to remember. Past this, the least recently
+	// used position is forgotten.
+	MaxPositions int
+
+	// Positions submitted to /position, keyed by id. Each element holds
+	// a storedPosition, and positionOrder keeps them most recently used
+	// first.
+	positions map[string]*list.Element
+	positionOrder *list.List
+	positionsMutex sync.Mutex
+	nextPosition int
+}
+
+type storedPosition struct {
+	id string
+	board *NaiveBoard
+}
+
+type APIRequest struct {
+	Board *NaiveBoard
+	Position string
+	Player string
+	Seconds float64
+}
+
+type APIMoveResponse struct {
+	Move NaiveSpot
+	WinRate float64
+}
+
+type APIAnalysisResponse struct {
+	Analysis Analysis
+
+	// The expected win rate for each spot, indexed by row and column.
+	// Spots the player didn't consider are null.
+	Heatmap [BoardSize][BoardSize]*float64
+}
+
+type APIPuzzleResponse struct {
+	Puzzle string
+	Move NaiveSpot
+	WinRate float64
+
+	// Whether the move was right, and whether the win rate was right too
+	Correct bool
+	Solved bool
+}
+
+type apiError struct {
+	status int
+	message string
+}
+
+func (e apiError) Error() string {
+	return e.message
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return apiError{http.StatusBadRequest, fmt.Sprintf(format, args...)}
+}
+
+func NewAPIServer(maxSeconds float64) *APIServer {
+	return &APIServer{
+		MaxSeconds: maxSeconds,
+		DefaultPlayer: "mcts1",
+		MaxPositions: 1000,
+		positions: make(map[string]*list.Element),
+		positionOrder: list.New(),
+	}
+}
+
+func (s *APIServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/position", s.handle("POST", s.servePosition))
+	mux.HandleFunc("/play", s.handle("POST", s.servePlay))
+	mux.HandleFunc("/analyze", s.handle("POST", s.serveAnalyze))
+	mux.HandleFunc("/puzzle", s.handle("GET", s.servePuzzle))
+	mux.HandleFunc("/stream", s.serveStream)
+	return mux
+}
+
+func (s *APIServer) ListenAndServe(address string) error {
+	log.Printf("api listening on http://%s", address)
+	return http.ListenAndServe(address, s.Handler())
+}
+
+// Wraps an endpoint that returns either an object to encode or an
+// error.
+func (s *APIServer) handle(method string,
+	f func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		err := checkMethod(r, method)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		response, err := f(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, response)
+	}
+}
+
+func checkMethod(r *http.Request, method string) error {
+	if r.Method != method {
+		return apiError{http.StatusMethodNotAllowed,
+			fmt.Sprintf("%s requires %s", r.URL.Path, method)}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, response interface{}) {
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("could not write response: %s", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	apiErr, ok := err.(apiError)
+	if ok {
+		status = apiErr.status
+	}
+	w.WriteHeader(status)
+	writeJSON(w, map[string]string{"Error": err.Error()})
+}
+
+func (s *APIServer) decodeRequest(r *http.Request) (APIRequest, error) {
+	var request APIRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		return request, badRequest("bad json: %s", err)
+	}
+	return request, nil
+}
+
+// Finds the board a request refers to.
+func (s *APIServer) board(request APIRequest) (*NaiveBoard, error) {
+	board := request.Board
+	if board == nil {
+		board = s.lookupPosition(request.Position)
+		if board == nil {
+			return nil, badRequest("no board or known position in request")
+		}
+	}
+	err := board.Validate()
+	if err != nil {
+		return nil, badRequest("%s", err)
+	}
+	if len(board.PossibleMoves()) == 0 {
+		return nil, badRequest("the board is full")
+	}
+	return board.ToNaiveBoard(), nil
+}
+
+// Creates a fresh player for one request, with its time budget capped.
+// The request should stop it pondering once it's done, since nothing
+// will use it again.
+func (s *APIServer) player(playerType string, seconds float64) (
+	Player, error) {
+	if playerType == "" {
+		playerType = s.DefaultPlayer
+	}
+	player, err := LookupPlayer(playerType)
+	if err != nil {
+		return nil, badRequest("%s", err)
+	}
+	timed, ok := player.(TimedPlayer)
+	if ok {
+		if seconds <= 0 {
+			seconds = s.MaxSeconds
+		}
+		timed.SetSeconds(math.Min(seconds, s.MaxSeconds))
+	}
+	return player, nil
+}
+
+func (s *APIServer) servePosition(r *http.Request) (interface{}, error) {
+	request, err := s.decodeRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	if request.Board == nil {
+		return nil, badRequest("no board in request")
+	}
+	board, err := s.board(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{"Position": s.storePosition(board)}, nil
+}
+
+// Returns the stored position with this id, or nil if there is none,
+// and marks it as recently used.
+func (s *APIServer) lookupPosition(id string) *NaiveBoard {
+	s.positionsMutex.Lock()
+	defer s.positionsMutex.Unlock()
+	element, ok := s.positions[id]
+	if !ok {
+		return nil
+	}
+	s.positionOrder.MoveToFront(element)
+	return element.Value.(storedPosition).board
+}
+
+// Stores a position and returns its id, forgetting the least recently
+// used positions if there are more than MaxPositions.
+func (s *APIServer) storePosition(board *NaiveBoard) string {
+	s.positionsMutex.Lock()
+	defer s.positionsMutex.Unlock()
+	s.nextPosition++
+	id := strconv.Itoa(s.nextPosition)
+	s.positions[id] = s.positionOrder.PushFront(storedPosition{id, board})
+	for s.positionOrder.Len() > s.MaxPositions {
+		oldest := s.positionOrder.Remove(s.positionOrder.Back())
+		delete(s.positions, oldest.(storedPosition).id)
+	}
+	return id
+}
+
+func (s *APIServer) servePlay(r *http.Request) (interface{}, error) {
+	request, err := s.decodeRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	board, err := s.board(request)
+	if err != nil {
+		return nil, err
+	}
+	player, err := s.player(request.Player, request.Seconds)
+	if err != nil {
+		return nil, err
+	}
+	defer StopPondering(player)
+	move, winRate := player.Play(board)
+	return APIMoveResponse{Move: move, WinRate: winRate}, nil
+}
+
+func (s *APIServer) serveStream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	err := checkMethod(r, "POST")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	request, err := s.decodeRequest(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	board, err := s.board(request)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	player, err := s.player(request.Player, request.Seconds)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer StopPondering(player)
+
+	flusher, _ := w.(http.Flusher)
+	observable, ok := player.(ObservablePlayer)
+	if ok {
+		observable.SetObserver(SearchObserverFunc(func(p SearchProgress) {
+			writeJSON(w, map[string]SearchProgress{"Progress": p})
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}))
+	}
+	move, winRate := player.Play(board)
+	writeJSON(w, APIMoveResponse{Move: move, WinRate: winRate})
+}
+
+func (s *APIServer) serveAnalyze(r *http.Request) (interface{}, error) {
+	request, err := s.decodeRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	board, err := s.board(request)
+	if err != nil {
+		return nil, err
+	}
+	player, err := s.player(request.Player, request.Seconds)
+	if err != nil {
+		return nil, err
+	}
+	defer StopPondering(player)
+	analyzer, ok := player.(Analyzer)
+	if !ok {
+		return nil, badRequest("%s cannot analyze positions", request.Player)
+	}
+
+	response := APIAnalysisResponse{Analysis: analyzer.Analyze(board)}
+	for _, move := range response.Analysis.Moves {
+		winRate := move.WinRate
+		response.Heatmap[move.Move.Row()][move.Move.Col()] = &winRate
+	}
+	return response, nil
+}
+
+func (s *APIServer) servePuzzle(r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	name := query.Get("name")
+	puzzle, err := LookupPuzzle(name)
+	if err != nil {
+		return nil, apiError{http.StatusNotFound, err.Error()}
+	}
+	var seconds float64
+	if query.Get("seconds") != "" {
+		var err error
+		seconds, err = strconv.ParseFloat(query.Get("seconds"), 64)
+		if err != nil {
+			return nil, badRequest("bad seconds: %s", query.Get("seconds"))
+		}
+	}
+	player, err := s.player(query.Get("player"), seconds)
+	if err != nil {
+		return nil, err
+	}
+	defer StopPondering(player)
+
+	move, winRate := player.Play(puzzle.Board.ToNaiveBoard())
+	return APIPuzzleResponse{
+		Puzzle: name,
+		Move: move,
+		WinRate: winRate,
+		Correct: puzzle.IsAnswer(move),
+		Solved: puzzle.IsSolvedBy(move, winRate),
+	}, nil
+}
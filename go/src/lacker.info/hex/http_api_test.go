@@ -0,0 +1,103 @@
+package hex
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func apiCall(t *testing.T, server *httptest.Server, method string,
+	path string, body string, status int) map[string]interface{} {
+	request, err := http.NewRequest(method, server.URL + path,
+		strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != status {
+		t.Fatalf("%s %s: expected status %d but got %d",
+			method, path, status, response.StatusCode)
+	}
+	var answer map[string]interface{}
+	err = json.NewDecoder(response.Body).Decode(&answer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return answer
+}
+
+func TestAPIServer(t *testing.T) {
+	server := httptest.NewServer(NewAPIServer(0.01).Handler())
+	defer server.Close()
+	board := ToJSON(PuzzleMap["onePly"].Board)
+
+	position := apiCall(t, server, "POST", "/position",
+		`{"Board":` + board + `}`, http.StatusOK)
+	id, ok := position["Position"].(string)
+	if !ok {
+		t.Fatalf("no position id in %v", position)
+	}
+
+	play := apiCall(t, server, "POST", "/play",
+		`{"Position":"` + id + `","Player":"random"}`, http.StatusOK)
+	if play["Move"] == nil {
+		t.Fatalf("no move in %v", play)
+	}
+
+	puzzle := apiCall(t, server, "GET", "/puzzle?name=onePly&player=random",
+		"", http.StatusOK)
+	if puzzle["Puzzle"] != "onePly" {
+		t.Fatalf("bad puzzle response: %v", puzzle)
+	}
+
+	errors := []struct {
+		method string
+		path string
+		body string
+		status int
+	}{
+		{"GET", "/play", "", http.StatusMethodNotAllowed},
+		{"POST", "/play", "nope", http.StatusBadRequest},
+		{"POST", "/play", `{"Position":"nope"}`, http.StatusBadRequest},
+		{"POST", "/play", `{"Position":"` + id + `","Player":"nope"}`,
+			http.StatusBadRequest},
+		{"POST", "/analyze", `{"Position":"` + id + `","Player":"random"}`,
+			http.StatusBadRequest},
+		{"GET", "/puzzle?name=nope", "", http.StatusNotFound},
+	}
+	for _, e := range errors {
+		response := apiCall(t, server, e.method, e.path, e.body, e.status)
+		if response["Error"] == nil {
+			t.Fatalf("%s %s should have returned an error", e.method, e.path)
+		}
+	}
+}
+
+func TestAPIServerForgetsOldPositions(t *testing.T) {
+	s := NewAPIServer(0.01)
+	s.MaxPositions = 2
+	board := PuzzleMap["onePly"].Board.ToNaiveBoard()
+	first := s.storePosition(board)
+	second := s.storePosition(board)
+
+	// Using the first position makes the second the least recently used
+	if s.lookupPosition(first) == nil {
+		t.Fatalf("position %s should be stored", first)
+	}
+	third := s.storePosition(board)
+	if s.lookupPosition(second) != nil {
+		t.Fatalf("position %s should have been forgotten", second)
+	}
+	if s.lookupPosition(first) == nil || s.lookupPosition(third) == nil {
+		t.Fatalf("positions %s and %s should be stored", first, third)
+	}
+	if len(s.positions) != 2 {
+		t.Fatalf("expected 2 positions but got %d", len(s.positions))
+	}
+}
@@ -0,0 +1,238 @@
+package hex
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+/*
+A Match plays complete games between two players, so that we can
+judge whether a change to a player made it stronger.
+Unlike QuickGame, the players can be any Player and each game can
+start from a different position.
+*/
+
+// Plays a complete game from the opening, which may be nil for the
+// empty board. The record's player types are left blank.
+// A player that makes an illegal move forfeits.
+// Neither player is left pondering once the game is over.
+func PlayGame(black Player, white Player, opening *NaiveBoard) GameRecord {
+	defer StopPondering(black)
+	defer StopPondering(white)
+
+	record := GameRecord{
+		Moves: make([]NaiveSpot, 0),
+		WinRates: make([]float64, 0),
+	}
+	if opening != nil {
+		record.Opening = opening.ToNaiveBoard()
+	}
+
+	board := record.StartingBoard().ToTopoBoard()
+	for board.Winner == Empty {
+		player := black
+		if board.ToMove == White {
+			player = white
+		}
+		move, winRate := player.Play(board.ToNaiveBoard())
+		if move.IsNotASpot() || board.Get(move) != Empty {
+			log.Printf("%s made an illegal move %s and forfeits",
+				board.ToMove.Name(), move)
+			record.Winner = -board.ToMove
+			return record
+		}
+		board.MakeMove(move)
+		record.Moves = append(record.Moves, move)
+		record.WinRates = append(record.WinRates, winRate)
+	}
+	record.Winner = board.Winner
+	return record
+}
+
+// Creates fresh players of the given types and plays a game between
+// them.
+func PlayGameBetween(blackType string, whiteType string,
+	opening *NaiveBoard) (GameRecord, error) {
+	black, err := LookupPlayer(blackType)
+	if err != nil {
+		return GameRecord{}, err
+	}
+	white, err := LookupPlayer(whiteType)
+	if err != nil {
+		return GameRecord{}, err
+	}
+	record := PlayGame(black, white, opening)
+	record.Black = blackType
+	record.White = whiteType
+	return record, nil
+}
+
+type Match struct {
+	// The player types, as passed to GetPlayer
+	Player1 string
+	Player2 string
+
+	Games int
+
+	// Whether to swap colors every game. Otherwise Player1 is always
+	// Black.
+	Alternate bool
+
+	// Positions to start games from, used in turn. When alternating,
+	// each opening is played once with each coloring.
+	// Empty means every game starts from the empty board.
+	Openings []*NaiveBoard
+
+	// Where to append each game record, if set
+	RecordPath string
+
+	// If set, the match stops as soon as this test reaches a decision
+	// about Player1. Games is then a maximum, where zero means no
+	// limit.
+	SPRT *SPRT
+}
+
+// Whether Player1 plays Black in the given game, counting from zero.
+func (m *Match) Player1IsBlack(game int) bool {
+	return !m.Alternate || game % 2 == 0
+}
+
+// The opening for the given game, counting from zero.
+func (m *Match) Opening(game int) *NaiveBoard {
+	if len(m.Openings) == 0 {
+		return nil
+	}
+	if m.Alternate {
+		game /= 2
+	}
+	return m.Openings[game % len(m.Openings)]
+}
+
+// Plays one game of the match, counting from zero.
+func (m *Match) PlayGame(game int) (GameRecord, error) {
+	if m.Player1IsBlack(game) {
+		return PlayGameBetween(m.Player1, m.Player2, m.Opening(game))
+	}
+	return PlayGameBetween(m.Player2, m.Player1, m.Opening(game))
+}
+
+// Whether the match is over before playing the given game.
+func (m *Match) finished(game int) bool {
+	if m.SPRT != nil {
+		if m.SPRT.Status() != SPRTContinue {
+			return true
+		}
+		if m.Games <= 0 {
+			return false
+		}
+	}
+	return game >= m.Games
+}
+
+// Plays all the games of the match, saving records as they finish.
+func (m *Match) Run() (*MatchResult, error) {
+	result := &MatchResult{Player1: m.Player1, Player2: m.Player2}
+	if m.SPRT != nil {
+		err := m.SPRT.Validate()
+		if err != nil {
+			return nil, err
+		}
+		result.SPRT = m.SPRT
+	}
+	for game := 0; !m.finished(game); game++ {
+		record, err := m.PlayGame(game)
+		if err != nil {
+			return nil, err
+		}
+		if m.RecordPath != "" {
+			err = AppendGameRecord(m.RecordPath, record)
+			if err != nil {
+				return nil, err
+			}
+		}
+		result.Add(record, m.Player1IsBlack(game))
+		log.Printf("game %d: %s (Black) vs %s (White). %s wins in %d moves",
+			game + 1, record.Black, record.White, record.Winner.Name(),
+			len(record.Moves))
+
+		if m.SPRT != nil {
+			m.SPRT.Add((record.Winner == Black) == m.Player1IsBlack(game))
+			log.Printf("%s", m.SPRT)
+		}
+	}
+	return result, nil
+}
+
+// A WinCount is how many games were won out of how many were played.
+type WinCount struct {
+	Wins int
+	Games int
+}
+
+func (c *WinCount) Add(won bool) {
+	c.Games++
+	if won {
+		c.Wins++
+	}
+}
+
+func (c WinCount) String() string {
+	if c.Games == 0 {
+		return "0/0"
+	}
+	low, high := WilsonInterval(c.Wins, c.Games)
+	return fmt.Sprintf("%d/%d = %.1f%% (95%% CI %.1f%%-%.1f%%)",
+		c.Wins, c.Games, 100.0 * float64(c.Wins) / float64(c.Games),
+		100.0 * low, 100.0 * high)
+}
+
+type MatchResult struct {
+	Player1 string
+	Player2 string
+
+	// Wins by each player overall and with each color
+	Player1Wins WinCount
+	Player1AsBlack WinCount
+	Player1AsWhite WinCount
+	Player2Wins WinCount
+	Player2AsBlack WinCount
+	Player2AsWhite WinCount
+
+	// How often Black won, regardless of who played it
+	BlackWins WinCount
+
+	// The sequential test on Player1, if the match ran one
+	SPRT *SPRT `json:",omitempty"`
+}
+
+func (r *MatchResult) Add(record GameRecord, player1IsBlack bool) {
+	player1Won := (record.Winner == Black) == player1IsBlack
+	r.Player1Wins.Add(player1Won)
+	r.Player2Wins.Add(!player1Won)
+	if player1IsBlack {
+		r.Player1AsBlack.Add(player1Won)
+		r.Player2AsWhite.Add(!player1Won)
+	} else {
+		r.Player1AsWhite.Add(player1Won)
+		r.Player2AsBlack.Add(!player1Won)
+	}
+	r.BlackWins.Add(record.Winner == Black)
+}
+
+func (r *MatchResult) Report() string {
+	lines := []string{
+		fmt.Sprintf("%s vs %s", r.Player1, r.Player2),
+		fmt.Sprintf("%s wins %s", r.Player1, r.Player1Wins),
+		fmt.Sprintf("  as Black %s", r.Player1AsBlack),
+		fmt.Sprintf("  as White %s", r.Player1AsWhite),
+		fmt.Sprintf("%s wins %s", r.Player2, r.Player2Wins),
+		fmt.Sprintf("  as Black %s", r.Player2AsBlack),
+		fmt.Sprintf("  as White %s", r.Player2AsWhite),
+		fmt.Sprintf("Black wins %s", r.BlackWins),
+	}
+	if r.SPRT != nil {
+		lines = append(lines, r.SPRT.String())
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,105 @@
+package hex
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlayGame(t *testing.T) {
+	record := PlayGame(Random{}, Random{}, nil)
+	if record.Winner == Empty {
+		t.Fatalf("a complete game should have a winner")
+	}
+	if len(record.Moves) != len(record.WinRates) {
+		t.Fatalf("there should be a win rate for every move")
+	}
+	board, err := record.BoardAtPly(len(record.Moves))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if board.Winner() != record.Winner {
+		t.Fatalf("the final board should show the same winner")
+	}
+	_, err = record.BoardAtPly(len(record.Moves) + 1)
+	if err == nil {
+		t.Fatalf("there should be no board past the end of the game")
+	}
+}
+
+func TestPlayGameFromOpening(t *testing.T) {
+	opening := PuzzleMap["onePly"].Board
+	record := PlayGame(Random{}, Random{}, opening)
+	if record.Opening == opening {
+		t.Fatalf("the record should keep its own copy of the opening")
+	}
+	if record.PlayerForPly(0) != record.Black {
+		t.Fatalf("Black is to move in the onePly opening")
+	}
+}
+
+func TestPlayGameStopsPondering(t *testing.T) {
+	spec := "mcts:seconds=0,playouts=50,ponder=true,quiet=true"
+	black := GetPlayer(spec).(*MonteCarloTreeSearch)
+	white := GetPlayer(spec).(*MonteCarloTreeSearch)
+	PlayGame(black, white, NewSmallBoard(4))
+	if black.stopPondering != nil || white.stopPondering != nil {
+		t.Fatalf("neither player should be pondering after the game")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "match")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	match := Match{
+		Player1: "random",
+		Player2: "random",
+		Games: 4,
+		Alternate: true,
+		RecordPath: filepath.Join(dir, "games.jsonl"),
+	}
+	result, err := match.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Player1Wins.Wins + result.Player2Wins.Wins != 4 {
+		t.Fatalf("every game should have a winner")
+	}
+	if result.Player1AsBlack.Games != 2 || result.Player2AsBlack.Games != 2 {
+		t.Fatalf("colors should alternate")
+	}
+
+	records, err := ReadGameRecords(match.RecordPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected 4 records but got %d", len(records))
+	}
+}
+
+func TestMatchSPRT(t *testing.T) {
+	// Random against itself is a coin flip, so a test between 0 and a
+	// huge elo gap should quickly accept H0.
+	match := Match{
+		Player1: "random",
+		Player2: "random",
+		Alternate: true,
+		SPRT: &SPRT{Elo0: 0, Elo1: 400, Alpha: 0.2, Beta: 0.2},
+	}
+	result, err := match.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SPRT.Status() == SPRTContinue {
+		t.Fatalf("the match should run until the test decides")
+	}
+	if result.Player1Wins.Games != result.SPRT.Wins + result.SPRT.Losses {
+		t.Fatalf("the test should count every game")
+	}
+}
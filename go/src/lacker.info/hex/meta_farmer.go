@@ -28,8 +28,10 @@ The meta farmer with delta nets:
 TODO
 */
 
+// The meta farmer has no randomness, so it can't be seeded. Each cycle
+// counts as a playout for its search limits.
 type MetaFarmer struct {
-	Seconds float64
+	SearchLimits
 	Quiet bool
 
 	// The players we are farming
@@ -50,6 +52,25 @@ type MetaFarmer struct {
 	cycles int
 }
 
+func init() {
+	RegisterPlayerType(PlayerType{
+		Name: "mf",
+		Help: "meta farmer. evolves a pair of quick players against each other",
+		Options: []PlayerOption{
+			{"seconds", "5", "how long to think per move"},
+			{"type", "democracy", "the quick player, democracy or deltanet"},
+			{"quiet", "false", "whether to skip logging"},
+		},
+		Make: func(o *PlayerOptions) Player {
+			return &MetaFarmer{
+				SearchLimits: o.Limits(),
+				Quiet: o.Bool("quiet"),
+				QuickType: o.Choice("type", "democracy", "deltanet"),
+			}
+		},
+	})
+}
+
 func (mf *MetaFarmer) init(b *TopoBoard) {
 	switch mf.QuickType {
 	case "democracy":
@@ -221,7 +242,7 @@ func (mf MetaFarmer) Play(b Board) (NaiveSpot, float64) {
 		}
 	} else {
 		start := time.Now()
-		for SecondsSince(start) < mf.Seconds {
+		for !mf.Reached(start, mf.cycles, 0) {
 			mf.PlayOneCycle(false)
 			if mf.gameSolved {
 				break
@@ -7,13 +7,13 @@ import (
 
 func TestMetaFarmerInit(t *testing.T) {
 	board := NewTopoBoard()
-	mf := &MetaFarmer{Seconds:-1, Quiet:true, QuickType:"democracy"}
+	mf := &MetaFarmer{SearchLimits: SearchLimits{Seconds: -1}, Quiet:true, QuickType:"democracy"}
 	mf.init(board)
 }
 
 func TestMetaFarmerWithDemocracyOnDoomed1(t *testing.T) {
 	board := PuzzleMap["doomed1"].Board.ToTopoBoard()
-	mf := &MetaFarmer{Seconds:-1, Quiet:true, QuickType:"democracy"}
+	mf := &MetaFarmer{SearchLimits: SearchLimits{Seconds: -1}, Quiet:true, QuickType:"democracy"}
 	mf.init(board)
 	mf.PlayOneCycle(false)
 	mf.PlayOneCycle(false)
@@ -35,7 +35,7 @@ func TestMetaFarmerWithDemocracyOnDoomed1(t *testing.T) {
 
 func TestMetaFarmerWithDeltaNetOnDoomed3(t *testing.T) {
 	board := PuzzleMap["doomed3"].Board.ToTopoBoard()
-	mf := &MetaFarmer{Seconds:-1, Quiet:true, QuickType:"deltanet"}
+	mf := &MetaFarmer{SearchLimits: SearchLimits{Seconds: -1}, Quiet:true, QuickType:"deltanet"}
 	mf.init(board)
 	mf.PlayOneCycle(false)
 	mf.PlayOneCycle(false)
@@ -44,7 +44,7 @@ func TestMetaFarmerWithDeltaNetOnDoomed3(t *testing.T) {
 
 func TestMetaFarmerWithDeltaNetOnDoomed6(t *testing.T) {
 	board := PuzzleMap["doomed3"].Board.ToTopoBoard()
-	mf := &MetaFarmer{Seconds:-1, Quiet:true, QuickType:"deltanet"}
+	mf := &MetaFarmer{SearchLimits: SearchLimits{Seconds: -1}, Quiet:true, QuickType:"deltanet"}
 	mf.init(board)
 	mf.PlayOneCycle(false)
 	mf.PlayOneCycle(false)
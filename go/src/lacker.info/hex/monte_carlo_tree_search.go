@@ -2,12 +2,23 @@ package hex
 
 /*
 Monte Carlo Tree Search.
+
+The search also proves wins and losses where it can, like MCTS-Solver.
+A node whose position has a winner is proven as soon as it's made.
+After that, a node is a proven win for the player to move if any child
+is a proven win for them, and a proven loss if every move is a proven
+loss. Proven nodes don't need playouts, and their exact values replace
+the estimates when picking moves.
 */
 
 import (
 	"fmt"
 	"log"
 	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,9 +27,21 @@ type TreeNode struct {
 	WhiteWins int
 	Board Board
 	NumPossibleMoves int
-	Children map[NaiveSpot]*TreeNode
+
+	// In the order they were expanded
+	Children []TreeEdge
+
+	// When transpositions are shared, a node can have several parents.
+	// This is the one it was first reached from.
 	Parent *TreeNode
 
+	// A zobrist hash of the position, for finding transpositions
+	Key int64
+
+	// The player who wins with perfect play, once the search has proven
+	// it. Empty until then.
+	Proven Color
+
 	// Holds parameters that may affect the search
 	Strategy *MonteCarloTreeSearch
 
@@ -28,15 +51,38 @@ type TreeNode struct {
 	// some point in the playout.
 	// With "topo" logic, this counts the number of times that this spot
 	// was part of a winning path for each player.
-	RaveBlackWins [NumSpots]int
-	RaveWhiteWins [NumSpots]int
+	// They're int32 to keep nodes small, since they're most of a node.
+	RaveBlackWins [NumSpots]int32
+	RaveWhiteWins [NumSpots]int32
+}
+
+// A move from a node, and the node it leads to. The move is a TopoSpot
+// because that's smaller.
+type TreeEdge struct {
+	Move TopoSpot
+	Node *TreeNode
+}
+
+// The child for a move, or nil if it hasn't been expanded.
+func (n *TreeNode) Child(move NaiveSpot) *TreeNode {
+	spot := move.TopoSpot()
+	for _, edge := range n.Children {
+		if edge.Move == spot {
+			return edge.Node
+		}
+	}
+	return nil
+}
+
+func (n *TreeNode) addChild(move NaiveSpot, child *TreeNode) {
+	n.Children = append(n.Children, TreeEdge{Move: move.TopoSpot(), Node: child})
 }
 
 func NewChild(parent *TreeNode, move NaiveSpot) *TreeNode {
 	if parent == nil {
 		panic("cannot create a child of nil")
 	}
-	if parent.Children[move] != nil {
+	if parent.Child(move) != nil {
 		panic("cannot create a duplicate child")
 	}
 	node := new(TreeNode)
@@ -50,13 +96,47 @@ func NewChild(parent *TreeNode, move NaiveSpot) *TreeNode {
 		node.Board = parent.Board.ToNaiveBoard()
 	}
 	node.Board.MakeMove(move)
-	parent.Children[move] = node
-	node.Children = make(map[NaiveSpot]*TreeNode)
+	parent.addChild(move, node)
 	node.NumPossibleMoves = parent.NumPossibleMoves - 1
 	node.Parent = parent
+	node.Key = parent.Key ^ moveZobrist(parent.Board.GetToMove(), move)
+	node.Proven = boardWinner(node.Board)
 	return node
 }
 
+// The winner of a finished game, or Empty.
+func boardWinner(b Board) Color {
+	switch board := b.(type) {
+	case *TopoBoard:
+		return board.Winner
+	case *NaiveBoard:
+		return board.Winner()
+	}
+	panic("unknown board type")
+}
+
+// Marks this node as proven if its children prove it.
+func (n *TreeNode) prove() {
+	if n.Proven != Empty {
+		return
+	}
+	toMove := n.Board.GetToMove()
+	lost := 0
+	for _, edge := range n.Children {
+		child := edge.Node
+		if child.Proven == toMove {
+			n.Proven = toMove
+			return
+		}
+		if child.Proven == -toMove {
+			lost++
+		}
+	}
+	if lost > 0 && lost == n.NumPossibleMoves {
+		n.Proven = -toMove
+	}
+}
+
 func (n *TreeNode) NumPlayouts() int {
 	return n.BlackWins + n.WhiteWins
 }
@@ -78,7 +158,13 @@ func (n *TreeNode) SimpleExpectedWinRate() float64 {
 // it to make the move that *gets* to this node.
 // Thus, it is optimizing for the player that is *not* to move.
 func (n *TreeNode) UCT() float64 {
-	if n.Parent == nil {
+	return n.UCTFrom(n.Parent)
+}
+
+// The UCT formula for moving to this node from a particular parent,
+// which matters when transpositions give a node several parents.
+func (n *TreeNode) UCTFrom(parent *TreeNode) float64 {
+	if parent == nil {
 		// With no parent there are no alternative choices so this node
 		// is infinitely promising
 		return math.Inf(1)
@@ -95,7 +181,7 @@ func (n *TreeNode) UCT() float64 {
 		// Always prefer an unexplored node
 		return math.Inf(1)
 	}
-	total := n.Parent.NumPlayouts()
+	total := parent.NumPlayouts()
 	return (wins / sims) + 0.5 * math.Sqrt(Fastlog(total) / sims)
 }
 
@@ -110,14 +196,17 @@ func (n *TreeNode) ToMoveLetter() string {
 }
 
 // Finds the move from this node with the most MCTS simulations.
+// Ties go to the first move in spot order.
 // Panics if it can't find any move.
 func (n *TreeNode) MostSimulatedMove() NaiveSpot {
 	var bestMove NaiveSpot
 	numSims := -1
 
-	for move, child := range n.Children {
-		childSims := child.BlackWins + child.WhiteWins
-		if childSims > numSims {
+	for _, edge := range n.Children {
+		move := edge.Move.NaiveSpot()
+		childSims := edge.Node.NumPlayouts()
+		if childSims > numSims ||
+			(childSims == numSims && move.Index() < bestMove.Index()) {
 			bestMove = move
 			numSims = childSims
 		}
@@ -141,11 +230,11 @@ func (n *TreeNode) SelectLeafByUCT() *TreeNode {
 
 	bestUCT := math.Inf(-1)
 	var bestChild *TreeNode
-	for _, child := range n.Children {
-		UCT := child.UCT()
+	for _, edge := range n.Children {
+		UCT := edge.Node.UCTFrom(n)
 		if UCT > bestUCT {
 			bestUCT = UCT
-			bestChild = child
+			bestChild = edge.Node
 		}
 	}
 
@@ -161,14 +250,22 @@ func (n *TreeNode) SelectLeafByUCT() *TreeNode {
 // Returns the child if expansion was possible, or nil if it was not
 // possible.
 func (n *TreeNode) Expand() *TreeNode {
+	return n.ExpandWith(globalRand)
+}
+
+// Like Expand, with a particular source of randomness.
+func (n *TreeNode) ExpandWith(r *rand.Rand) *TreeNode {
 	if n.NumPossibleMoves <= len(n.Children) {
 		return nil
 	}
+	var expanded [NumSpots]bool
+	for _, edge := range n.Children {
+		expanded[edge.Move.NaiveSpot().Index()] = true
+	}
 	possibleMoves := n.Board.PossibleMoves()
-	ShuffleSpots(possibleMoves)
+	ShuffleSpotsWith(r, possibleMoves)
 	for _, move := range possibleMoves {
-		_, ok := n.Children[move]
-		if !ok {
+		if !expanded[move.Index()] {
 			return NewChild(n, move)
 		}
 	}
@@ -180,8 +277,8 @@ func (n *TreeNode) Depth() int {
 		return 0
 	}
 	answer := 1
-	for _, child := range n.Children {
-		answer = Intmax(answer, child.Depth() + 1)
+	for _, edge := range n.Children {
+		answer = Intmax(answer, edge.Node.Depth() + 1)
 	}
 	return answer
 }
@@ -189,6 +286,26 @@ func (n *TreeNode) Depth() int {
 // Backpropagate a win, starting at this node and continuing
 // through parents until we hit the root.
 func (n *TreeNode) Backprop(winner Color, finalBoard Board) {
+	n.record(winner, finalBoard)
+	n.prove()
+	if n.Parent != nil {
+		n.Parent.Backprop(winner, finalBoard)
+	}
+}
+
+// The board of a proven node, if the game is over on it, or nil for a
+// node proven by its children.
+func (n *TreeNode) finishedBoard() Board {
+	if boardWinner(n.Board) == Empty {
+		return nil
+	}
+	return n.Board
+}
+
+// Counts a playout through this node. A nil finalBoard means the round
+// ended at a node proven by its children, which has no final position
+// to update rave stats from.
+func (n *TreeNode) record(winner Color, finalBoard Board) {
 	// Update regular win/loss stats
 	switch winner {
 	case Black:
@@ -196,6 +313,9 @@ func (n *TreeNode) Backprop(winner Color, finalBoard Board) {
 	case White:
 		n.WhiteWins++
 	}
+	if finalBoard == nil {
+		return
+	}
 
 	// Update rave stats.
 	if n.Strategy.UseTopoBoards {
@@ -232,10 +352,6 @@ func (n *TreeNode) Backprop(winner Color, finalBoard Board) {
 			}
 		}
 	}
-
-	if n.Parent != nil {
-		n.Parent.Backprop(winner, finalBoard)
-	}
 }
 
 func (n *TreeNode) String() string {
@@ -245,7 +361,8 @@ func (n *TreeNode) String() string {
 }
 
 type MonteCarloTreeSearch struct {
-	Seconds float64
+	SearchLimits
+	SeededRand
 	Quiet bool
 
 	// Parameter controlling rave mixing.
@@ -253,17 +370,137 @@ type MonteCarloTreeSearch struct {
 
 	// Whether to use topo boards
 	UseTopoBoards bool
+
+	// Gets told about progress during the search, if set
+	Observer SearchObserver
+
+	// Whether to keep the tree between searches. The next search then
+	// starts from the node for its position, if the tree has one.
+	Reuse bool
+
+	// How many goroutines search at once. Each one searches its own
+	// tree and the trees are added up at the end, which is called root
+	// parallelization. Zero means one.
+	Threads int
+
+	// Whether positions reached by different move orders share one
+	// node, which makes the tree a graph. The search then remembers the
+	// path it took to backprop along, since a node can have several
+	// parents. Each parent keeps its own rave stats, so rave still
+	// depends on how a position was reached.
+	Transpositions bool
+
+	// Whether Play goes on searching after it moves, on the position
+	// after its move, until the next search or StopPondering. That
+	// needs Reuse, so that the next search can start where it left off.
+	Ponder bool
+
+	// The most nodes pondering adds before it stops by itself, so that a
+	// player nobody stops pondering doesn't search forever. 0 means
+	// DefaultPonderMegabytes worth of nodes.
+	PonderNodes int
+
+	// The most nodes the trees can hold between them, or 0 for no limit.
+	// When a tree fills up, the children of its least simulated nodes
+	// get dropped, and those nodes are leaves again until the search
+	// comes back to them. So a search can go on for as long as it
+	// likes in the same memory, which matters for long searches and for
+	// pondering. A tree always gets room for every move from its root.
+	MaxNodes int
+
+	// The roots of the last search, one per thread, when reusing trees
+	trees []*TreeNode
+
+	// The game clock for the next search, if there is one
+	clock *GameClock
+
+	// While pondering, closing stopPondering stops it, and pondered is
+	// closed once it has stopped.
+	stopPondering chan bool
+	pondered chan bool
+}
+
+func (mcts *MonteCarloTreeSearch) SetObserver(observer SearchObserver) {
+	mcts.Observer = observer
 }
 
 func MakeMCTS(seconds float64) MonteCarloTreeSearch {
 	return MonteCarloTreeSearch{
-		Seconds: seconds,
+		SearchLimits: SearchLimits{Seconds: seconds},
 		Quiet: false,
 		V: 1000,
 		UseTopoBoards: false,
+		Reuse: true,
+	}
+}
+
+func init() {
+	RegisterPlayerType(PlayerType{
+		Name: "mcts",
+		Help: "monte carlo tree search with rave",
+		Options: []PlayerOption{
+			{"seconds", "5", "how long to think per move"},
+			{"v", "1000", "playouts before rave stops mattering. 0 for backoff"},
+			{"topo", "false", "whether to use topo boards and rave"},
+			{"reuse", "true", "whether to keep the tree between moves"},
+			{"threads", "1", "how many goroutines to search with"},
+			{"tt", "false", "whether transpositions share a node"},
+			{"ponder", "false", "whether to think during the opponent's turn"},
+			{"pondernodes", "0", "the most nodes to add while pondering. 0 for the default"},
+			{"maxnodes", "0", "the most nodes to keep. 0 for no limit"},
+			{"maxmb", "0", "the most megabytes of nodes to keep. 0 for no limit"},
+			{"quiet", "false", "whether to skip logging"},
+		},
+		Make: func(o *PlayerOptions) Player {
+			mcts := MakeMCTS(0)
+			mcts.SearchLimits = o.Limits()
+			mcts.V = o.Int("v")
+			mcts.UseTopoBoards = o.Bool("topo")
+			mcts.Reuse = o.Bool("reuse")
+			mcts.Threads = o.Int("threads")
+			mcts.Transpositions = o.Bool("tt")
+			mcts.Ponder = o.Bool("ponder")
+			mcts.PonderNodes = o.Int("pondernodes")
+			mcts.MaxNodes = o.Int("maxnodes")
+			megabytes := o.Float("maxmb")
+			if megabytes > 0 {
+				nodes := NodesInMegabytes(megabytes, mcts.UseTopoBoards)
+				if mcts.MaxNodes == 0 || nodes < mcts.MaxNodes {
+					mcts.MaxNodes = nodes
+				}
+			}
+			mcts.Quiet = o.Bool("quiet")
+			return &mcts
+		},
+	})
+}
+
+
+// About how many bytes a node takes up, with its board and its edge
+// from its parent, measured from the heap after long searches. See
+// TestNodeBytes.
+const NaiveNodeBytes = 1300
+const TopoNodeBytes = 1800
+
+// How many nodes fit in this many megabytes.
+func NodesInMegabytes(megabytes float64, topo bool) int {
+	bytes := NaiveNodeBytes
+	if topo {
+		bytes = TopoNodeBytes
 	}
+	return Intmax(int(megabytes * 1e6 / float64(bytes)), 1)
 }
 
+// How much memory pondering may add to the tree when PonderNodes isn't
+// set.
+const DefaultPonderMegabytes = 200
+
+func (mcts *MonteCarloTreeSearch) ponderNodes() int {
+	if mcts.PonderNodes > 0 {
+		return mcts.PonderNodes
+	}
+	return NodesInMegabytes(DefaultPonderMegabytes, mcts.UseTopoBoards)
+}
 
 func (mcts *MonteCarloTreeSearch) NewRoot(b Board) *TreeNode {
 	node := new(TreeNode)
@@ -272,9 +509,10 @@ func (mcts *MonteCarloTreeSearch) NewRoot(b Board) *TreeNode {
 	} else {
 		node.Board = b.ToNaiveBoard()
 	}
-	node.Children = make(map[NaiveSpot]*TreeNode)
 	node.NumPossibleMoves = len(node.Board.PossibleMoves())
 	node.Strategy = mcts
+	node.Key = b.ToTopoBoard().Zobrist()
+	node.Proven = boardWinner(node.Board)
 	return node
 }
 
@@ -289,15 +527,21 @@ func (mcts *MonteCarloTreeSearch) NewRoot(b Board) *TreeNode {
 // In general this is not as good as a V around 1000.
 func (mcts *MonteCarloTreeSearch) ExpectedWinRate(
 	parent *TreeNode, move NaiveSpot, child *TreeNode, debug bool) float64 {
+	if child != nil && child.Proven != Empty {
+		if child.Proven == parent.Board.GetToMove() {
+			return 1.0
+		}
+		return 0.0
+	}
 	var raveWins int
 	var raveLosses int
 	switch parent.Board.GetToMove() {
 	case Black:
-		raveWins = parent.RaveBlackWins[move.Index()]
-		raveLosses = parent.RaveWhiteWins[move.Index()]
+		raveWins = int(parent.RaveBlackWins[move.Index()])
+		raveLosses = int(parent.RaveWhiteWins[move.Index()])
 	case White:
-		raveLosses = parent.RaveBlackWins[move.Index()]
-		raveWins = parent.RaveWhiteWins[move.Index()]
+		raveLosses = int(parent.RaveBlackWins[move.Index()])
+		raveWins = int(parent.RaveWhiteWins[move.Index()])
 	}
 
 	var raveWinRate float64
@@ -432,16 +676,31 @@ func (mcts *MonteCarloTreeSearch) ExpectedWinRate(
 }
 
 // Uses ExpectedWinRate to figure out which move is expected to be the
-// best.
+// best. Ties go to the first move in spot order, so that the search
+// doesn't depend on the order the children were expanded in.
 func (mcts *MonteCarloTreeSearch) ExpectedBestMove(n *TreeNode) (
 	NaiveSpot, *TreeNode, float64) {
 
-	bestWinRate := math.Inf(-1)
+	bestRank := math.Inf(-1)
+	bestWinRate := 0.0
 	var bestMove NaiveSpot
 	var bestChild *TreeNode
-	for move, child := range n.Children {
+	for _, edge := range n.Children {
+		move, child := edge.Move.NaiveSpot(), edge.Node
 		winRate := mcts.ExpectedWinRate(n, move, child, false)
-		if winRate > bestWinRate {
+
+		// Proven wins beat estimates, even ones that round to a sure win,
+		// and proven losses only get picked when everything is lost
+		rank := winRate
+		switch child.Proven {
+		case n.Board.GetToMove():
+			rank = 2.0
+		case -n.Board.GetToMove():
+			rank = -1.0
+		}
+		if rank > bestRank ||
+			(rank == bestRank && move.Index() < bestMove.Index()) {
+			bestRank = rank
 			bestWinRate = winRate
 			bestChild = child
 			bestMove = move
@@ -459,6 +718,9 @@ func (mcts *MonteCarloTreeSearch) ExpectedBestMove(n *TreeNode) (
 // A leaf node is defined as a node where either a new child could be added,
 // or there are no possible children and the game is over.
 func (mcts *MonteCarloTreeSearch) SelectLeaf(n *TreeNode) *TreeNode {
+	if n.Proven != Empty {
+		return n
+	}
 	if n.NumPossibleMoves > len(n.Children) {
 		return n
 	}
@@ -472,24 +734,591 @@ func (mcts *MonteCarloTreeSearch) SelectLeaf(n *TreeNode) *TreeNode {
 }
 
 func (mcts *MonteCarloTreeSearch) RunOneRound(n *TreeNode) {
-	leaf := mcts.SelectLeaf(n).Expand()
+	mcts.runOneRound(n, mcts.Rand(), nil)
+}
+
+// Does one round of search. When transpositions are shared, the table
+// has every node in the graph by key.
+// Returns how many nodes the round added to the tree.
+func (mcts *MonteCarloTreeSearch) runOneRound(n *TreeNode, r *rand.Rand,
+	table map[int64]*TreeNode) int {
+	if table != nil {
+		return mcts.runOneGraphRound(n, r, table)
+	}
+	leaf := mcts.SelectLeaf(n)
+	if leaf.Proven != Empty {
+		// No need for a playout when the result is known
+		leaf.Backprop(leaf.Proven, leaf.finishedBoard())
+		return 0
+	}
+	leaf = leaf.ExpandWith(r)
+	if leaf.Proven != Empty {
+		leaf.Backprop(leaf.Proven, leaf.finishedBoard())
+		return 1
+	}
 	board := leaf.Board.Copy()
-	winner := board.Playout()
+	winner := board.PlayoutWith(r)
 	leaf.Backprop(winner, board)
+	return 1
+}
+
+// Like runOneRound, for a graph where nodes can have several parents.
+func (mcts *MonteCarloTreeSearch) runOneGraphRound(n *TreeNode,
+	r *rand.Rand, table map[int64]*TreeNode) int {
+	size := len(table)
+	path := []*TreeNode{n}
+	for n.Proven == Empty && n.NumPossibleMoves == len(n.Children) {
+		parent := n
+		_, n, _ = mcts.ExpectedBestMove(parent)
+		path = append(path, n)
+		if n.Proven != Empty {
+			// It may have been proven through another parent
+			parent.prove()
+		}
+	}
+	if n.Proven == Empty {
+		n = n.expandShared(r, table)
+		path = append(path, n)
+	}
+	var board Board
+	var winner Color
+	if n.Proven != Empty {
+		board = n.finishedBoard()
+		winner = n.Proven
+	} else {
+		board = n.Board.Copy()
+		winner = board.PlayoutWith(r)
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		path[i].record(winner, board)
+		path[i].prove()
+	}
+	return len(table) - size
+}
+
+// Like ExpandWith, except that if the table already has a node for the
+// new position, that node becomes a child here too.
+func (n *TreeNode) expandShared(r *rand.Rand,
+	table map[int64]*TreeNode) *TreeNode {
+	var expanded [NumSpots]bool
+	for _, edge := range n.Children {
+		expanded[edge.Move.NaiveSpot().Index()] = true
+	}
+	possibleMoves := n.Board.PossibleMoves()
+	ShuffleSpotsWith(r, possibleMoves)
+	for _, move := range possibleMoves {
+		if expanded[move.Index()] {
+			continue
+		}
+		existing, ok := table[n.Key ^ moveZobrist(n.Board.GetToMove(), move)]
+		if ok {
+			n.addChild(move, existing)
+			return existing
+		}
+		child := NewChild(n, move)
+		table[child.Key] = child
+		return child
+	}
+	panic("children everywhere")
+}
+
+// Every node reachable from this one, by key.
+func (n *TreeNode) transpositionTable() map[int64]*TreeNode {
+	table := make(map[int64]*TreeNode)
+	stack := []*TreeNode{n}
+	for len(stack) > 0 {
+		node := stack[len(stack) - 1]
+		stack = stack[:len(stack) - 1]
+		_, ok := table[node.Key]
+		if ok {
+			continue
+		}
+		table[node.Key] = node
+		for _, edge := range node.Children {
+			stack = append(stack, edge.Node)
+		}
+	}
+	return table
 }
 
-func (mcts MonteCarloTreeSearch) Play(b Board) (NaiveSpot, float64) {
-	start := time.Now()
-	root := mcts.NewRoot(b)
+// How many nodes are reachable from this one.
+func (n *TreeNode) size() int {
+	seen := make(map[*TreeNode]bool)
+	stack := []*TreeNode{n}
+	for len(stack) > 0 {
+		node := stack[len(stack) - 1]
+		stack = stack[:len(stack) - 1]
+		if seen[node] {
+			continue
+		}
+		seen[node] = true
+		for _, edge := range node.Children {
+			stack = append(stack, edge.Node)
+		}
+	}
+	return len(seen)
+}
 
-	// Do playouts for a set amount of time
-	for SecondsSince(start) < mcts.Seconds {
-		mcts.RunOneRound(root)
+// Cuts the tree down to at most max nodes. The nodes with the most
+// playouts keep their children, and the rest lose them, becoming leaves
+// that keep their own stats and can be expanded again. The nodes that
+// get cut off are left for the garbage collector.
+// The root always keeps its children, since they're what the search is
+// choosing between, even if that's more than max.
+// Returns how many nodes are left.
+func (n *TreeNode) prune(max int) int {
+	// The nodes with children, parents before their children
+	var parents []*TreeNode
+	seen := map[*TreeNode]bool{n: true}
+	stack := []*TreeNode{n}
+	for len(stack) > 0 {
+		node := stack[len(stack) - 1]
+		stack = stack[:len(stack) - 1]
+		if len(node.Children) == 0 {
+			continue
+		}
+		parents = append(parents, node)
+		for i := len(node.Children) - 1; i >= 0; i-- {
+			child := node.Children[i].Node
+			if !seen[child] {
+				seen[child] = true
+				stack = append(stack, child)
+			}
+		}
 	}
 
+	// A node has more playouts than its children, so in a tree this
+	// never gets to a node before its parent. In a graph it can, and
+	// then that node just loses its children.
+	sort.SliceStable(parents, func(i, j int) bool {
+		return parents[i].NumPlayouts() > parents[j].NumPlayouts()
+	})
+	kept := map[*TreeNode]bool{n: true}
+	var open []*TreeNode
+	for _, node := range parents {
+		if !kept[node] {
+			continue
+		}
+		added := 0
+		for _, edge := range node.Children {
+			if !kept[edge.Node] {
+				added++
+			}
+		}
+		if node != n && len(kept) + added > max {
+			continue
+		}
+		for _, edge := range node.Children {
+			kept[edge.Node] = true
+		}
+		open = append(open, node)
+	}
+
+	opened := make(map[*TreeNode]bool)
+	for _, node := range open {
+		opened[node] = true
+	}
+	for _, node := range parents {
+		if kept[node] && !opened[node] {
+			node.Children = nil
+		}
+	}
+
+	// A shared node's first parent might be gone, and shouldn't be kept
+	// around by it
+	for _, node := range open {
+		for _, edge := range node.Children {
+			if !kept[edge.Node.Parent] {
+				edge.Node.Parent = node
+			}
+		}
+	}
+	return len(kept)
+}
+
+// Finds the node for b in this tree, if the game has only gone on from
+// here by moves that are in the tree, like our last move and the
+// opponent's reply. Returns nil if there is none.
+func (n *TreeNode) findDescendant(b Board) *TreeNode {
+	node := n
+
+	// The moves made since, in no particular order
+	moves := make(map[NaiveSpot]Color)
+	for _, spot := range AllSpots() {
+		before := node.Board.Get(spot)
+		after := b.Get(spot)
+		if before == after {
+			continue
+		}
+		if before != Empty {
+			return nil
+		}
+		moves[spot] = after
+	}
+
+	for len(moves) > 0 {
+		var next *TreeNode
+		for move, color := range moves {
+			child := node.Child(move)
+			if child != nil && color == node.Board.GetToMove() {
+				next = child
+				delete(moves, move)
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	if node.Board.GetToMove() != b.GetToMove() {
+		return nil
+	}
+	return node
+}
+
+// The roots to search b from, one per thread, reusing the last
+// search's trees where they have the position.
+func (mcts *MonteCarloTreeSearch) roots(b Board) []*TreeNode {
+	threads := Intmax(mcts.Threads, 1)
+	roots := make([]*TreeNode, threads)
+	reused := 0
+	for i := range roots {
+		if mcts.Reuse && i < len(mcts.trees) {
+			roots[i] = mcts.trees[i].findDescendant(b)
+		}
+		if roots[i] == nil {
+			roots[i] = mcts.NewRoot(b)
+			continue
+		}
+		// Let go of the rest of the old tree
+		roots[i].Parent = nil
+		if len(roots[i].Children) == 0 {
+			// Pruning took away the children that proved it, and there needs
+			// to be a child to move to
+			roots[i].Proven = Empty
+		}
+		reused += roots[i].NumPlayouts()
+	}
+	if reused > 0 && !mcts.Quiet {
+		log.Printf("reusing %d playouts", reused)
+	}
+	if mcts.Reuse {
+		mcts.trees = roots
+	} else {
+		mcts.trees = nil
+	}
+	return roots
+}
+
+// What the threads of one search share.
+type sharedSearch struct {
+	start time.Time
+
+	// Playouts on all the threads so far
+	playouts int64
+
+	// Closing this stops every thread. Nil if nothing stops the search
+	// early.
+	stop chan bool
+
+	// Decides when the search is done, when it has a game clock
+	keeper *timeKeeper
+
+	// The most nodes each thread's tree can have, or 0 for no limit
+	treeNodes int
+}
+
+// Shares out the search's node budget between the trees for roots.
+// Each tree gets at least enough to keep every move from the root when
+// it's pruned, since pruning never drops those.
+func (mcts *MonteCarloTreeSearch) newSharedSearch(
+	roots []*TreeNode) *sharedSearch {
+	shared := &sharedSearch{start: time.Now()}
+	if mcts.MaxNodes > 0 {
+		least := int(math.Ceil(float64(roots[0].NumPossibleMoves + 1) /
+			PruneFraction))
+		shared.treeNodes = Intmax(mcts.MaxNodes / len(roots), least)
+	}
+	return shared
+}
+
+// How far a full tree gets pruned. Pruning to less than the budget
+// means it isn't needed again for a while.
+const PruneFraction = 0.75
+
+// Does playouts on the tree until it reaches the limits, proves the
+// root, or the search is stopped. Always does at least one round, so
+// that the root has a child to pick.
+// Only the first thread tells the observer about progress, with the
+// playouts of all the threads, and asks the time keeper whether to
+// stop.
+func (mcts *MonteCarloTreeSearch) searchTree(root *TreeNode,
+	shared *sharedSearch, limits SearchLimits, r *rand.Rand, first bool) {
+	clock := newProgressClock()
+	var table map[int64]*TreeNode
+	if mcts.Transpositions {
+		table = root.transpositionTable()
+	}
+	nodes := 0
+	size := 0
+	if shared.treeNodes > 0 {
+		size = root.size()
+	}
+	for rounds := 0; rounds == 0 ||
+		(!limits.Reached(shared.start, rounds, nodes) &&
+			root.Proven == Empty && !closed(shared.stop)); rounds++ {
+		if shared.treeNodes > 0 && size >= shared.treeNodes {
+			size = root.prune(int(float64(shared.treeNodes) * PruneFraction))
+			if table != nil {
+				table = root.transpositionTable()
+			}
+		}
+		added := mcts.runOneRound(root, r, table)
+		nodes += added
+		size += added
+		total := atomic.AddInt64(&shared.playouts, 1)
+		if !first {
+			continue
+		}
+		if clock.due(mcts.Observer) {
+			progress := mcts.Progress(root, clock.seconds())
+			progress.Playouts += int(total) - rounds - 1
+			mcts.Observer.ObserveSearch(progress)
+		}
+		if shared.keeper != nil && rounds % timeKeeperRounds == 0 &&
+			shared.keeper.done(mcts, root, rounds + 1) {
+			close(shared.stop)
+		}
+	}
+}
+
+// Sets the game clock for the next search, which then plans its own
+// time instead of using the time limit. Other limits still apply.
+func (mcts *MonteCarloTreeSearch) SetClock(clock GameClock) {
+	mcts.clock = &clock
+}
+
+// Does playouts for the board until the search limits, on a tree per
+// thread. The threads split the playout and node limits between them.
+// With a game clock, the time comes from its plan instead.
+// The trees are new, unless the last search's trees have the position.
+// Returns the root of the tree, which with more than one thread is
+// the trees added up.
+func (mcts *MonteCarloTreeSearch) Search(b Board) *TreeNode {
+	mcts.StopPondering()
+	roots := mcts.roots(b)
+	shared := mcts.newSharedSearch(roots)
+	limits := mcts.SearchLimits
+	if mcts.clock != nil {
+		plan := PlanTime(*mcts.clock, b)
+		if !mcts.Quiet {
+			log.Printf("planning %.2fs, up to %.2fs", plan.Target, plan.Max)
+		}
+		limits.Seconds = plan.Max
+		shared.stop = make(chan bool)
+		shared.keeper = &timeKeeper{plan: plan, start: shared.start}
+		mcts.clock = nil
+		for _, root := range roots {
+			proveThreats(root)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, root := range roots[1:] {
+		wg.Add(1)
+		go func(root *TreeNode, limits SearchLimits, r *rand.Rand) {
+			defer wg.Done()
+			mcts.searchTree(root, shared, limits, r, false)
+		}(root, limits.Split(len(roots), i + 1), mcts.NewRand())
+	}
+	mcts.searchTree(roots[0], shared, limits.Split(len(roots), 0),
+		mcts.Rand(), true)
+	wg.Wait()
+	if !mcts.Quiet && shared.keeper != nil {
+		log.Printf("thought for %.2fs", SecondsSince(shared.start))
+	}
+
+	if len(roots) == 1 {
+		return roots[0]
+	}
+	return mcts.mergeRoots(roots)
+}
+
+// Whether a channel is closed. A nil channel never is.
+func closed(c chan bool) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+// Searches b in the background, on every thread, until StopPondering,
+// the next search, or it has added ponderNodes nodes. Typically b is
+// the position after our move, so that the tree is ready for whatever
+// the opponent replies. The search has no time limit, and doesn't tell
+// the observer anything.
+// Does nothing without Reuse, since nothing would come of it.
+func (mcts *MonteCarloTreeSearch) StartPondering(b Board) {
+	mcts.StopPondering()
+	if !mcts.Reuse || boardWinner(b) != Empty {
+		return
+	}
+	roots := mcts.roots(b)
+	shared := mcts.newSharedSearch(roots)
+	shared.stop = make(chan bool)
+	rands := make([]*rand.Rand, len(roots))
+	for i := range rands {
+		rands[i] = mcts.NewRand()
+	}
+	done := make(chan bool)
+	mcts.stopPondering = shared.stop
+	mcts.pondered = done
+
+	go func() {
+		defer close(done)
+		limits := SearchLimits{Seconds: math.Inf(1), Nodes: mcts.ponderNodes()}
+		var wg sync.WaitGroup
+		for i, root := range roots {
+			wg.Add(1)
+			go func(root *TreeNode, limits SearchLimits, r *rand.Rand) {
+				defer wg.Done()
+				mcts.searchTree(root, shared, limits, r, false)
+			}(root, limits.Split(len(roots), i), rands[i])
+		}
+		wg.Wait()
+		if !mcts.Quiet {
+			log.Printf("pondered %d playouts in %.1fs", shared.playouts,
+				SecondsSince(shared.start))
+		}
+	}()
+}
+
+// Stops pondering, and waits until the tree is safe to use again.
+// Does nothing if we aren't pondering.
+func (mcts *MonteCarloTreeSearch) StopPondering() {
+	if mcts.stopPondering == nil {
+		return
+	}
+	close(mcts.stopPondering)
+	<-mcts.pondered
+	mcts.stopPondering = nil
+	mcts.pondered = nil
+}
+
+// Adds another node's statistics to this one's.
+func (n *TreeNode) addStats(other *TreeNode) {
+	n.BlackWins += other.BlackWins
+	n.WhiteWins += other.WhiteWins
+	for i := range n.RaveBlackWins {
+		n.RaveBlackWins[i] += other.RaveBlackWins[i]
+		n.RaveWhiteWins[i] += other.RaveWhiteWins[i]
+	}
+}
+
+// Adds up the trees that the threads searched, making a new root whose
+// children have the total statistics for each move. Below that, each
+// child shares the subtree of whichever thread looked at its move the
+// most, which is enough to follow principal variations.
+func (mcts *MonteCarloTreeSearch) mergeRoots(roots []*TreeNode) *TreeNode {
+	merged := mcts.NewRoot(roots[0].Board)
+	most := make(map[NaiveSpot]int)
+	for _, root := range roots {
+		merged.addStats(root)
+		for _, edge := range root.Children {
+			move, child := edge.Move.NaiveSpot(), edge.Node
+			m := merged.Child(move)
+			if m == nil {
+				m = &TreeNode{
+					Board: child.Board,
+					NumPossibleMoves: child.NumPossibleMoves,
+					Parent: merged,
+					Strategy: mcts,
+				}
+				merged.addChild(move, m)
+			}
+			if child.NumPlayouts() > most[move] {
+				most[move] = child.NumPlayouts()
+				m.Children = child.Children
+			}
+			if child.Proven != Empty {
+				m.Proven = child.Proven
+			}
+			m.addStats(child)
+		}
+	}
+	merged.prove()
+	return merged
+}
+
+// Summarizes the search so far.
+func (mcts *MonteCarloTreeSearch) Progress(
+	root *TreeNode, seconds float64) SearchProgress {
+	move, child, score := mcts.ExpectedBestMove(root)
+	pv := []NaiveSpot{move}
+	pv = append(pv, mcts.PrincipalVariation(child)...)
+	return SearchProgress{
+		BestMove: move,
+		WinRate: score,
+		Playouts: root.NumPlayouts(),
+		Seconds: seconds,
+		PrincipalVariation: pv,
+	}
+}
+
+// The line of play that the search currently expects, starting after
+// the move that leads to n.
+func (mcts *MonteCarloTreeSearch) PrincipalVariation(
+	n *TreeNode) []NaiveSpot {
+	answer := make([]NaiveSpot, 0)
+	for len(n.Children) > 0 {
+		move, child, _ := mcts.ExpectedBestMove(n)
+		answer = append(answer, move)
+		n = child
+	}
+	return answer
+}
+
+// Describes the search tree from the root's point of view.
+func (mcts *MonteCarloTreeSearch) AnalyzeRoot(root *TreeNode) Analysis {
+	move, _, score := mcts.ExpectedBestMove(root)
+	analysis := Analysis{
+		Move: move,
+		WinRate: score,
+		Playouts: root.NumPlayouts(),
+		Moves: make([]MoveAnalysis, 0),
+	}
 	for _, move := range AllSpots() {
-		child, ok := root.Children[move]
-		if ok && !mcts.Quiet && (child.WhiteWins + child.BlackWins >= 0) {
+		child := root.Child(move)
+		if child == nil {
+			continue
+		}
+		pv := []NaiveSpot{move}
+		pv = append(pv, mcts.PrincipalVariation(child)...)
+		analysis.Moves = append(analysis.Moves, MoveAnalysis{
+			Move: move,
+			Playouts: child.NumPlayouts(),
+			WinRate: mcts.ExpectedWinRate(root, move, child, false),
+			RaveWinRate: mcts.ExpectedWinRate(root, move, nil, false),
+			PrincipalVariation: pv,
+		})
+	}
+	analysis.Sort()
+	return analysis
+}
+
+func (mcts *MonteCarloTreeSearch) Analyze(b Board) Analysis {
+	return mcts.AnalyzeRoot(mcts.Search(b))
+}
+
+func (mcts *MonteCarloTreeSearch) Play(b Board) (NaiveSpot, float64) {
+	root := mcts.Search(b)
+
+	for _, move := range AllSpots() {
+		child := root.Child(move)
+		if child != nil && !mcts.Quiet {
 			log.Printf("%s -- %s", move, child)			
 		}
 	}
@@ -512,11 +1341,17 @@ func (mcts MonteCarloTreeSearch) Play(b Board) (NaiveSpot, float64) {
 		rww := root.RaveWhiteWins[debugMove.Index()]
 		fmt.Printf("rave wins: B:%d W:%d total:%d\n", rbw, rww, rbw + rww)
 			
-		debugChild := root.Children[debugMove]
+		debugChild := root.Child(debugMove)
 		fmt.Printf("child: %s\n", debugChild.String())
 		debugWinRate := mcts.ExpectedWinRate(root, debugMove, debugChild, true)
 		fmt.Printf("expected win rate: %.4f\n", debugWinRate)
 	}
 
+	if mcts.Ponder {
+		after := b.ToNaiveBoard()
+		after.MakeMove(move)
+		mcts.StartPondering(after)
+	}
+
 	return move, score
 }
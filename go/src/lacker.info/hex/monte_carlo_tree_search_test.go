@@ -1,9 +1,13 @@
 package hex
 
 import (
+	"fmt"
 	"math"
 	"math/rand"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSimpleChain(t *testing.T) {
@@ -90,7 +94,7 @@ func TestTopoMCTS(t *testing.T) {
 
 func BenchmarkMCTS(b *testing.B) {
 	rand.Seed(1)
-	mcts := MonteCarloTreeSearch{Seconds: 0, Quiet: false, V: 1000}
+	mcts := MonteCarloTreeSearch{Quiet: false, V: 1000}
 	board := NewNaiveBoard()
 	root := mcts.NewRoot(board)
 
@@ -102,7 +106,7 @@ func BenchmarkMCTS(b *testing.B) {
 func BenchmarkTopoMCTS(b *testing.B) {
 	rand.Seed(1)
 	mcts := MonteCarloTreeSearch{
-		Seconds: 0, Quiet: false, V: 1000, UseTopoBoards: true,
+		Quiet: false, V: 1000, UseTopoBoards: true,
 	}
 	board := NewNaiveBoard()
 	root := mcts.NewRoot(board)
@@ -112,3 +116,495 @@ func BenchmarkTopoMCTS(b *testing.B) {
 	}
 
 }
+
+func TestMCTSAnalyze(t *testing.T) {
+	rand.Seed(1)
+	mcts := MakeMCTS(0)
+	mcts.Quiet = true
+	analysis := mcts.Analyze(PuzzleMap["onePly"].Board)
+	if len(analysis.Moves) == 0 {
+		t.Fatalf("the analysis should have at least one move")
+	}
+	if analysis.Moves[0].PrincipalVariation[0] != analysis.Moves[0].Move {
+		t.Fatalf("the principal variation should start with the move")
+	}
+	for i := 1; i < len(analysis.Moves); i++ {
+		if analysis.Moves[i].WinRate > analysis.Moves[i - 1].WinRate {
+			t.Fatalf("the analysis moves should be sorted")
+		}
+	}
+
+	top := analysis.Top(1)
+	if len(top.Moves) != 1 || top.Moves[0].Move != analysis.Moves[0].Move {
+		t.Fatalf("Top should keep the best move")
+	}
+	if !strings.Contains(top.Report(), analysis.Moves[0].Move.Notation()) {
+		t.Fatalf("the report should list the move:\n%s", top.Report())
+	}
+}
+
+func TestMCTSObserver(t *testing.T) {
+	oldInterval := ProgressInterval
+	ProgressInterval = 0
+	defer func() { ProgressInterval = oldInterval }()
+
+	mcts := MakeMCTS(0.05)
+	mcts.Quiet = true
+	reports := 0
+	mcts.SetObserver(SearchObserverFunc(func(progress SearchProgress) {
+		reports++
+		if progress.PrincipalVariation[0] != progress.BestMove {
+			t.Fatalf("the principal variation should start with the move")
+		}
+	}))
+	mcts.Play(NewNaiveBoard())
+	if reports == 0 {
+		t.Fatalf("the observer was never told about progress")
+	}
+}
+
+func TestMCTSTreeReuse(t *testing.T) {
+	for _, topo := range []bool{false, true} {
+		for _, transpositions := range []bool{false, true} {
+			checkTreeReuse(t, topo, transpositions)
+		}
+	}
+}
+
+func checkTreeReuse(t *testing.T, topo bool, transpositions bool) {
+	mcts := MakeMCTS(0.05)
+	mcts.Quiet = true
+	mcts.UseTopoBoards = topo
+	mcts.Transpositions = transpositions
+	board := NewSmallBoard(5)
+	mcts.Play(board)
+	if len(mcts.trees) != 1 {
+		t.Fatalf("the tree should be kept")
+	}
+	tree := mcts.trees[0]
+
+	// Play the moves the tree has looked at most
+	move := tree.MostSimulatedMove()
+	child := tree.Child(move)
+	reply := child.MostSimulatedMove()
+	grandchild := child.Child(reply)
+	board.MakeMove(move)
+	board.MakeMove(reply)
+	if tree.findDescendant(board) != grandchild {
+		t.Fatalf("the search should continue from the reply's node")
+	}
+	playouts := grandchild.NumPlayouts()
+	mcts.Play(board)
+	if mcts.trees[0] != grandchild || grandchild.Parent != nil ||
+		grandchild.NumPlayouts() <= playouts {
+		t.Fatalf("the search should have reused the reply's node")
+	}
+
+	// A position that isn't a descendant gets a new tree
+	other := NewSmallBoard(5)
+	other.MakeMove(MakeNaiveSpot(4, 4))
+	if grandchild.findDescendant(other) != nil {
+		t.Fatalf("an unrelated position should not reuse the tree")
+	}
+
+	mcts.Reuse = false
+	mcts.Play(board)
+	if mcts.trees != nil {
+		t.Fatalf("trees should not be kept when Reuse is off")
+	}
+}
+
+func TestEngineKeepsMCTSTree(t *testing.T) {
+	engine := NewEngine()
+	spec := "mcts:seconds=0.05,quiet=true"
+	board := NewSmallBoard(4)
+	engine.Handle(EngineRequest{Player: spec, Board: board}, nil)
+	tree := engine.players[spec].(*MonteCarloTreeSearch).trees[0]
+	move := tree.MostSimulatedMove()
+	board.MakeMove(move)
+	board.MakeMove(tree.Child(move).MostSimulatedMove())
+	if tree.findDescendant(board) == nil {
+		t.Fatalf("the engine's player should keep its tree between requests")
+	}
+}
+
+func TestMCTSPondering(t *testing.T) {
+	mcts := MakeMCTS(0)
+	mcts.Quiet = true
+	mcts.Playouts = 400
+	mcts.Threads = 2
+	board := NewNaiveBoard()
+	move, _ := mcts.Play(board)
+	board.MakeMove(move)
+	tree := mcts.trees[0].Child(move)
+	playouts := tree.NumPlayouts()
+	mcts.StartPondering(board)
+	time.Sleep(50 * time.Millisecond)
+	mcts.StopPondering()
+	mcts.StopPondering()
+	if mcts.trees[0] != tree || tree.NumPlayouts() <= playouts {
+		t.Fatalf("pondering should search the position after our move")
+	}
+	// It was a child, so it has a playout of its own
+	checkTreeStats(t, tree, false)
+
+	// With the option, Play ponders by itself, and the next move starts
+	// from what pondering found
+	mcts.Ponder = true
+	reply := tree.MostSimulatedMove()
+	board.MakeMove(reply)
+	node := tree.Child(reply)
+	playouts = node.NumPlayouts()
+	mcts.Play(board)
+	defer mcts.StopPondering()
+	if mcts.stopPondering == nil {
+		t.Fatalf("Play should have started pondering")
+	}
+	if node.NumPlayouts() < playouts + 200 {
+		t.Fatalf("the search should have started from the pondered node")
+	}
+}
+
+func TestMCTSPonderingStopsByItself(t *testing.T) {
+	mcts := MakeMCTS(0)
+	mcts.Quiet = true
+	mcts.Playouts = 100
+	mcts.PonderNodes = 50
+	board := NewNaiveBoard()
+	move, _ := mcts.Play(board)
+	board.MakeMove(move)
+	tree := mcts.trees[0].Child(move)
+	size := tree.size()
+	mcts.StartPondering(board)
+	select {
+	case <-mcts.pondered:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("pondering should stop after adding PonderNodes nodes")
+	}
+	mcts.StopPondering()
+	if tree.size() != size + 50 {
+		t.Fatalf("pondering grew the tree from %d to %d nodes", size,
+			tree.size())
+	}
+}
+
+// Checks that every node's playouts are the playouts of its children,
+// plus the one it started with. Proven nodes can have more, since
+// rounds stop at them.
+func checkTreeStats(t *testing.T, n *TreeNode, isRoot bool) {
+	sum := 0
+	for _, edge := range n.Children {
+		sum += edge.Node.NumPlayouts()
+		checkTreeStats(t, edge.Node, false)
+	}
+	if !isRoot {
+		sum++
+	}
+	if n.NumPlayouts() < sum || (n.Proven == Empty && n.NumPlayouts() != sum) {
+		t.Fatalf("a node has %d playouts but its children add up to %d",
+			n.NumPlayouts(), sum)
+	}
+}
+
+func TestParallelMCTS(t *testing.T) {
+	mcts := MakeMCTS(0.1)
+	mcts.Quiet = true
+	mcts.Threads = 4
+	board := NewSmallBoard(4)
+	root := mcts.Search(board)
+	if len(mcts.trees) != 4 {
+		t.Fatalf("expected a tree per thread but got %d", len(mcts.trees))
+	}
+
+	total := 0
+	for _, tree := range mcts.trees {
+		checkTreeStats(t, tree, true)
+		total += tree.NumPlayouts()
+	}
+	if root.NumPlayouts() != total {
+		t.Fatalf("the merged root has %d playouts but the trees have %d",
+			root.NumPlayouts(), total)
+	}
+	for _, edge := range root.Children {
+		move, child := edge.Move.NaiveSpot(), edge.Node
+		sum := 0
+		for _, tree := range mcts.trees {
+			c := tree.Child(move)
+			if c != nil {
+				sum += c.NumPlayouts()
+			}
+		}
+		if child.NumPlayouts() != sum {
+			t.Fatalf("the merged %s has %d playouts but the trees have %d",
+				move, child.NumPlayouts(), sum)
+		}
+	}
+
+	move, _ := mcts.Play(board)
+	if board.Get(move) != Empty {
+		t.Fatalf("illegal move %s", move)
+	}
+}
+
+// Playouts per second should grow about linearly with the cores.
+// Measures how playouts per second scale with threads, doubling up to
+// the number of cpus.
+func BenchmarkParallelPlayouts(b *testing.B) {
+	threadCounts := []int{}
+	for threads := 1; threads < runtime.NumCPU(); threads *= 2 {
+		threadCounts = append(threadCounts, threads)
+	}
+	threadCounts = append(threadCounts, runtime.NumCPU())
+	for _, threads := range threadCounts {
+		b.Run(fmt.Sprintf("threads=%d", threads), func(b *testing.B) {
+			mcts := MakeMCTS(0.2)
+			mcts.Quiet = true
+			mcts.Reuse = false
+			mcts.Threads = threads
+			playouts := 0
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				playouts += mcts.Search(NewNaiveBoard()).NumPlayouts()
+			}
+			b.ReportMetric(float64(playouts) / SecondsSince(start), "playouts/s")
+		})
+	}
+}
+
+func TestMCTSTranspositions(t *testing.T) {
+	mcts := MakeMCTS(0.2)
+	mcts.Quiet = true
+	mcts.Transpositions = true
+	root := mcts.Search(NewSmallBoard(3))
+
+	// Every position has one node, and some have several parents
+	table := root.transpositionTable()
+	edges := 0
+	for key, node := range table {
+		if node.Board.ToTopoBoard().Zobrist() != key {
+			t.Fatalf("a node's key doesn't match its position")
+		}
+		edges += len(node.Children)
+	}
+	if edges <= len(table) - 1 {
+		t.Fatalf("expected shared nodes but the %d nodes have %d edges",
+			len(table), edges)
+	}
+
+	mcts.Threads = 2
+	move, _ := mcts.Play(PuzzleMap["onePly"].Board)
+	if move != MakeNaiveSpot(10, 0) {
+		t.Fatalf("expected the winning move but got %s", move)
+	}
+}
+
+func TestMCTSSolver(t *testing.T) {
+	// The second search starts at a proven root, which has no winner on
+	// its board to count rave stats from
+	for _, topo := range []bool{false, true} {
+		for _, transpositions := range []bool{false, true} {
+			mcts := MakeMCTS(2)
+			mcts.Quiet = true
+			mcts.UseTopoBoards = topo
+			mcts.Transpositions = transpositions
+			for i := 0; i < 2; i++ {
+				move, winRate := mcts.Play(PuzzleMap["onePly"].Board)
+				if move != MakeNaiveSpot(10, 0) || winRate != 1.0 {
+					t.Fatalf("expected a proven win at (10, 0) but got %s at %.3f",
+						move, winRate)
+				}
+				if mcts.trees[0].Proven != Black {
+					t.Fatalf("the root should be proven")
+				}
+			}
+		}
+	}
+	mcts := MakeMCTS(2)
+	mcts.Quiet = true
+
+	// A move with only rave wins looks like a sure win, but shouldn't
+	// beat one that's proven
+	root := mcts.NewRoot(PuzzleMap["onePly"].Board)
+	lucky := MakeNaiveSpot(5, 7)
+	NewChild(root, lucky)
+	NewChild(root, MakeNaiveSpot(10, 0))
+	root.RaveBlackWins[lucky.Index()] = 10
+	move, _, _ := mcts.ExpectedBestMove(root)
+	if move != MakeNaiveSpot(10, 0) {
+		t.Fatalf("expected the proven win but got %s", move)
+	}
+
+	// After each winning move on 3x3, White is lost, and the search
+	// should prove it
+	oracle, _ := NewOracle(3)
+	for _, winning := range oracle.WinningMoves(NewSmallBoard(3)) {
+		board := NewSmallBoard(3)
+		board.MakeMove(winning)
+		for _, topo := range []bool{false, true} {
+			for _, transpositions := range []bool{false, true} {
+				mcts := MakeMCTS(2)
+				mcts.Quiet = true
+				mcts.UseTopoBoards = topo
+				mcts.Transpositions = transpositions
+				_, winRate := mcts.Play(board)
+				if winRate != 0.0 || mcts.trees[0].Proven != Black {
+					t.Fatalf("after %s White should be proven lost, not %.3f",
+						winning, winRate)
+				}
+			}
+		}
+	}
+}
+
+func TestMCTSNodeBudget(t *testing.T) {
+	mcts := MakeMCTS(0)
+	mcts.Quiet = true
+	mcts.Playouts = 2000
+	root := mcts.Search(NewSmallBoard(5))
+	size := root.prune(500)
+	if size > 500 || size != root.size() || len(root.Children) == 0 {
+		t.Fatalf("pruning to 500 nodes left %d, %d of them reachable", size,
+			root.size())
+	}
+
+	// Even with less room than that, the root keeps every move
+	size = root.prune(10)
+	if size != len(root.Children) + 1 || len(root.Children) != 25 {
+		t.Fatalf("pruning to 10 nodes left %d, with %d moves from the root",
+			size, len(root.Children))
+	}
+
+	for _, transpositions := range []bool{false, true} {
+		for _, threads := range []int{1, 2} {
+			mcts := MakeMCTS(0)
+			mcts.Quiet = true
+			mcts.Playouts = 3000
+			mcts.MaxNodes = 400
+			mcts.Transpositions = transpositions
+			mcts.Threads = threads
+			mcts.Search(NewNaiveBoard())
+			for _, tree := range mcts.trees {
+				if tree.size() > 400 / threads {
+					t.Fatalf("a tree has %d nodes, over its budget", tree.size())
+				}
+			}
+
+			mcts.Playouts = 0
+			mcts.Seconds = 1
+			move, _ := mcts.Play(PuzzleMap["onePly"].Board)
+			if move != MakeNaiveSpot(10, 0) {
+				t.Fatalf("expected the winning move but got %s", move)
+			}
+		}
+	}
+
+	// A budget smaller than the number of moves still keeps the playouts
+	// of every move
+	mcts.MaxNodes = 50
+	mcts.Seconds = 0
+	mcts.Playouts = 5000
+	root = mcts.Search(NewNaiveBoard())
+	sum := 0
+	for _, edge := range root.Children {
+		sum += edge.Node.NumPlayouts()
+	}
+	if len(root.Children) != NumSpots || sum != root.NumPlayouts() {
+		t.Fatalf("only %d of %d playouts are in the %d moves left", sum,
+			root.NumPlayouts(), len(root.Children))
+	}
+	if root.size() > 163 {
+		t.Fatalf("the tree has %d nodes, over its raised budget", root.size())
+	}
+
+	// A proven root that lost its children to pruning still has to move
+	mcts.Seconds = 1
+	mcts.Play(PuzzleMap["onePly"].Board)
+	mcts.trees[0].Children = nil
+	move, _ := mcts.Play(PuzzleMap["onePly"].Board)
+	if move != MakeNaiveSpot(10, 0) {
+		t.Fatalf("expected the winning move but got %s", move)
+	}
+}
+
+// Compares searching with and without transpositions on puzzles that
+// need some depth, with the same playouts and seeds each way. The
+// solved metric is the fraction of searches that find a right answer.
+// Iteration i uses seed i + 1, so run with a fixed count, like
+// -benchtime 10x, to repeat a measurement.
+func BenchmarkTranspositions(b *testing.B) {
+	for _, name := range []string{"needle", "triangleBlock"} {
+		puzzle := PuzzleMap[name]
+		for _, tt := range []bool{false, true} {
+			b.Run(fmt.Sprintf("%s/tt=%t", name, tt), func(b *testing.B) {
+				solved := 0
+				for i := 0; i < b.N; i++ {
+					mcts := MakeMCTS(0)
+					mcts.Playouts = 5000
+					mcts.Quiet = true
+					mcts.Reuse = false
+					mcts.Transpositions = tt
+					mcts.SetSeed(int64(i + 1))
+					move, _ := mcts.Play(puzzle.Board)
+					if puzzle.IsAnswer(move) {
+						solved++
+					}
+				}
+				b.ReportMetric(float64(solved) / float64(b.N), "solved")
+			})
+		}
+	}
+}
+
+// The node sizes the memory budget goes by should match the heap.
+func TestNodeBytes(t *testing.T) {
+	for _, topo := range []bool{false, true} {
+		expected := NaiveNodeBytes
+		if topo {
+			expected = TopoNodeBytes
+		}
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		mcts := MakeMCTS(0)
+		mcts.Quiet = true
+		mcts.UseTopoBoards = topo
+		mcts.Playouts = 20000
+		mcts.SetSeed(1)
+		root := mcts.Search(NewNaiveBoard())
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		bytes := float64(after.HeapAlloc - before.HeapAlloc) /
+			float64(root.size())
+		if math.Abs(bytes - float64(expected)) > 0.1 * float64(expected) {
+			t.Fatalf("with topo=%t a node takes %.0f bytes, not about %d", topo,
+				bytes, expected)
+		}
+	}
+}
+
+// Compares how often a search solves puzzles with and without a node
+// budget small enough that it has to prune.
+func BenchmarkNodeBudget(b *testing.B) {
+	for _, name := range []string{"needle", "triangleBlock"} {
+		puzzle := PuzzleMap[name]
+		for _, maxNodes := range []int{0, 1000} {
+			b.Run(fmt.Sprintf("%s/maxnodes=%d", name, maxNodes),
+				func(b *testing.B) {
+					solved := 0
+					for i := 0; i < b.N; i++ {
+						mcts := MakeMCTS(0)
+						mcts.Playouts = 5000
+						mcts.Quiet = true
+						mcts.Reuse = false
+						mcts.MaxNodes = maxNodes
+						mcts.SetSeed(int64(i + 1))
+						move, _ := mcts.Play(puzzle.Board)
+						if puzzle.IsAnswer(move) {
+							solved++
+						}
+					}
+					b.ReportMetric(float64(solved) / float64(b.N), "solved")
+				})
+		}
+	}
+}
@@ -2,7 +2,10 @@ package hex
 
 import (
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"log"
+	"math/rand"
 	"strings"
 )
 
@@ -24,6 +27,28 @@ func NewNaiveBoard() *NaiveBoard {
 	return &NaiveBoard{ToMove: Black}
 }
 
+// Makes an empty board that plays like a size x size board.
+// The playing area is the top-left corner, rows and columns 0 through
+// size - 1. Everything below it is filled with Black and everything to
+// its right with White, so reaching the bottom of the playing area
+// connects Black to the bottom, and reaching its right side connects
+// White to the right. Since the rest of the board is full, it's
+// exactly the smaller game.
+func NewSmallBoard(size int) *NaiveBoard {
+	if size < 1 || size > BoardSize {
+		log.Fatalf("cannot make a board of size %d", size)
+	}
+	b := NewNaiveBoard()
+	for _, spot := range AllSpots() {
+		if spot.Row() >= size {
+			b.Set(spot, Black)
+		} else if spot.Col() >= size {
+			b.Set(spot, White)
+		}
+	}
+	return b
+}
+
 func (b *NaiveBoard) Get(spot Spot) Color {
 	return b.Board[spot.Row()][spot.Col()]
 }
@@ -122,8 +147,12 @@ func (b *NaiveBoard) Copy() Board {
 // Returns the winner.
 // This mutates the board.
 func (b *NaiveBoard) Playout() Color {
+	return b.PlayoutWith(globalRand)
+}
+
+func (b *NaiveBoard) PlayoutWith(r *rand.Rand) Color {
 	moves := b.PossibleMoves()
-	ShuffleSpots(moves)
+	ShuffleSpotsWith(r, moves)
 
 	for _, move := range moves {
 		b.MakeMoveWithNaiveSpot(move)
@@ -192,14 +221,63 @@ func (b *NaiveBoard) Winner() Color {
 }
 
 func NewNaiveBoardFromJSON(j string) *NaiveBoard {
-	b := new(NaiveBoard)
-	err := json.Unmarshal([]byte(j[:]), &b)
+	b, err := ParseNaiveBoardJSON(j)
 	if err != nil {
 		log.Fatal("NewNaiveBoardFromJSON failed: ", err)
 	}
 	return b
 }
 
+// Like NewNaiveBoardFromJSON but returns an error rather than dying,
+// for callers like servers that need to survive bad input.
+func ParseNaiveBoardJSON(j string) (*NaiveBoard, error) {
+	b := new(NaiveBoard)
+	err := json.Unmarshal([]byte(j[:]), &b)
+	if err != nil {
+		return nil, err
+	}
+	err = b.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Reads a file with one board in json form per line.
+func ReadNaiveBoards(path string) ([]*NaiveBoard, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	answer := make([]*NaiveBoard, 0)
+	for i, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		b, err := ParseNaiveBoardJSON(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, i + 1, err)
+		}
+		answer = append(answer, b)
+	}
+	return answer, nil
+}
+
+// Checks that the board only contains valid colors and that somebody
+// is to move.
+func (b *NaiveBoard) Validate() error {
+	if b.ToMove != Black && b.ToMove != White {
+		return fmt.Errorf("bad ToMove: %d", b.ToMove)
+	}
+	for _, spot := range AllSpots() {
+		color := b.Get(spot)
+		if color != Black && color != White && color != Empty {
+			return fmt.Errorf("bad color at %s: %d", spot, color)
+		}
+	}
+	return nil
+}
+
 func (b *NaiveBoard) GetWinningPathSpots() []NaiveSpot {
 	panic("not implemented")
 }
@@ -72,3 +72,29 @@ func BenchmarkBoardPlayout(b *testing.B) {
 		board.Playout()
 	}
 }
+
+func TestSmallBoard(t *testing.T) {
+	b := NewSmallBoard(3)
+	if len(b.PossibleMoves()) != 9 {
+		t.Fatalf("a size 3 board should have 9 possible moves")
+	}
+	if b.Winner() != Empty || b.ToTopoBoard().Winner != Empty {
+		t.Fatalf("nobody should have won the empty small board")
+	}
+
+	black := NewSmallBoard(3)
+	for r := 0; r < 3; r++ {
+		black.Set(MakeNaiveSpot(r, 2), Black)
+	}
+	if black.Winner() != Black || black.ToTopoBoard().Winner != Black {
+		t.Fatalf("a column should win for Black on a small board")
+	}
+
+	white := NewSmallBoard(3)
+	for c := 0; c < 3; c++ {
+		white.Set(MakeNaiveSpot(1, c), White)
+	}
+	if white.Winner() != White || white.ToTopoBoard().Winner != White {
+		t.Fatalf("a row should win for White on a small board")
+	}
+}
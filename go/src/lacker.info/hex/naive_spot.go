@@ -1,7 +1,10 @@
 package hex
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 type NaiveSpot struct {
@@ -52,6 +55,51 @@ func (s NaiveSpot) String() string {
 	return fmt.Sprintf("(%d, %d)", s.Row(), s.Col())
 }
 
+// Spots are encoded in json as {"Row":r,"Col":c}, which is the format
+// the python scripts expect.
+type jsonSpot struct {
+	Row int
+	Col int
+}
+
+func (s NaiveSpot) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonSpot{Row: s.Row(), Col: s.Col()})
+}
+
+func (s *NaiveSpot) UnmarshalJSON(data []byte) error {
+	var j jsonSpot
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return err
+	}
+	spot := MakeNaiveSpot(j.Row, j.Col)
+	if spot.IsNotASpot() {
+		return fmt.Errorf("spot (%d, %d) is off the board", j.Row, j.Col)
+	}
+	*s = spot
+	return nil
+}
+
+// The standard notation for a spot is a letter for the column and a
+// number for the row, both starting from the top left, like "a1".
+func (s NaiveSpot) Notation() string {
+	return fmt.Sprintf("%c%d", 'a' + s.Col(), s.Row() + 1)
+}
+
+func ParseNotation(text string) (NaiveSpot, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if len(text) < 2 {
+		return NaiveSpot{}, fmt.Errorf("bad spot: %q", text)
+	}
+	col := int(text[0] - 'a')
+	row, err := strconv.Atoi(text[1:])
+	spot := MakeNaiveSpot(row - 1, col)
+	if err != nil || spot.IsNotASpot() {
+		return NaiveSpot{}, fmt.Errorf("bad spot: %q", text)
+	}
+	return spot, nil
+}
+
 func (s NaiveSpot) Transpose() NaiveSpot {
 	return MakeNaiveSpot(s.Col(), s.Row())
 }
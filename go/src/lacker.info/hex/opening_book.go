@@ -0,0 +1,408 @@
+package hex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"sync"
+)
+
+/*
+An OpeningBook remembers good moves for positions early in the game,
+so a player doesn't have to search the empty board every game.
+
+Positions are keyed by a zobrist hash that is the same for all the
+symmetric versions of a position. Hex has four symmetries: turning the
+board halfway around keeps each player's sides, and flipping it along
+either diagonal swaps Black's sides with White's, so it also swaps the
+colors of the stones. Moves are stored as they would be played in the
+canonical version of the position, the one with the smallest hash.
+The hashes are the same in every run, so a book can be saved to a file
+and used later.
+
+A book can be filled by importing game records, or by expanding it with
+a search. Expanding follows the line that looks best so far, analyzes
+the first position off the end of the book, and backs the new win rates
+up the line. Once a line reaches MaxPly or the end of the game, the
+next best line gets expanded instead, so over many hours the book grows
+deepest along the lines that matter, and keeps growing until every line
+is complete.
+*/
+
+type BookMove struct {
+	Move NaiveSpot
+
+	// The win rate for the player making the move
+	WinRate float64
+
+	// How much evidence there is for the win rate, in games or playouts
+	Weight int
+}
+
+type BookEntry struct {
+	Moves []BookMove
+}
+
+// The move with the best win rate among those with at least minWeight
+// evidence.
+func (e *BookEntry) Best(minWeight int) (BookMove, bool) {
+	var best BookMove
+	found := false
+	for _, move := range e.Moves {
+		if move.Weight < minWeight {
+			continue
+		}
+		if !found || move.WinRate > best.WinRate {
+			best = move
+			found = true
+		}
+	}
+	return best, found
+}
+
+// Adds evidence about a move, averaging by weight with what's there.
+func (e *BookEntry) Add(move NaiveSpot, winRate float64, weight int) {
+	for i, m := range e.Moves {
+		if m.Move != move {
+			continue
+		}
+		total := m.Weight + weight
+		if total > 0 {
+			e.Moves[i].WinRate = (m.WinRate * float64(m.Weight) +
+				winRate * float64(weight)) / float64(total)
+		}
+		e.Moves[i].Weight = total
+		return
+	}
+	e.Moves = append(e.Moves, BookMove{move, winRate, weight})
+}
+
+type OpeningBook struct {
+	Positions map[int64]*BookEntry
+
+	// Positions with more moves than this are never added
+	MaxPly int
+}
+
+func NewOpeningBook(maxPly int) *OpeningBook {
+	return &OpeningBook{
+		Positions: make(map[int64]*BookEntry),
+		MaxPly: maxPly,
+	}
+}
+
+// A symmetry of the board. The flips also swap the colors.
+type symmetry int
+const (
+	identity symmetry = iota
+	halfTurn
+	diagonalFlip
+	antidiagonalFlip
+)
+
+var symmetries = []symmetry{identity, halfTurn, diagonalFlip,
+	antidiagonalFlip}
+
+// Every symmetry undoes itself.
+func (sym symmetry) spot(s NaiveSpot) NaiveSpot {
+	last := BoardSize - 1
+	switch sym {
+	case halfTurn:
+		return MakeNaiveSpot(last - s.Row(), last - s.Col())
+	case diagonalFlip:
+		return MakeNaiveSpot(s.Col(), s.Row())
+	case antidiagonalFlip:
+		return MakeNaiveSpot(last - s.Col(), last - s.Row())
+	}
+	return s
+}
+
+func (sym symmetry) color(c Color) Color {
+	if sym == diagonalFlip || sym == antidiagonalFlip {
+		return -c
+	}
+	return c
+}
+
+func (sym symmetry) zobrist(b *NaiveBoard) int64 {
+	var answer int64
+	for _, spot := range AllSpots() {
+		index := sym.spot(spot).TopoSpot()
+		switch sym.color(b.Get(spot)) {
+		case Black:
+			answer ^= blackZobrist[index]
+		case White:
+			answer ^= whiteZobrist[index]
+		}
+	}
+	if sym.color(b.ToMove) == White {
+		answer ^= whiteToMoveZobrist
+	}
+	return answer
+}
+
+// The key for a position, and the symmetry that takes it to the
+// canonical version.
+func canonicalKey(b *NaiveBoard) (int64, symmetry) {
+	bestKey := identity.zobrist(b)
+	bestSym := identity
+	for _, sym := range symmetries[1:] {
+		key := sym.zobrist(b)
+		if key < bestKey {
+			bestKey = key
+			bestSym = sym
+		}
+	}
+	return bestKey, bestSym
+}
+
+// The entry for a position, or nil if it isn't in the book, along with
+// the symmetry that book moves for it need to be played with.
+func (book *OpeningBook) Lookup(b Board) (*BookEntry, symmetry) {
+	key, sym := canonicalKey(b.ToNaiveBoard())
+	return book.Positions[key], sym
+}
+
+// Adds evidence about a move in a position.
+func (book *OpeningBook) Add(b Board, move NaiveSpot, winRate float64,
+	weight int) {
+	key, sym := canonicalKey(b.ToNaiveBoard())
+	entry, ok := book.Positions[key]
+	if !ok {
+		entry = &BookEntry{Moves: make([]BookMove, 0)}
+		book.Positions[key] = entry
+	}
+	entry.Add(sym.spot(move), winRate, weight)
+}
+
+// Adds the opening moves of a game, each counting as one game won or
+// lost by the player who made it.
+func (book *OpeningBook) Import(record GameRecord) error {
+	for ply := 0; ply < len(record.Moves) && ply < book.MaxPly; ply++ {
+		board, err := record.BoardAtPly(ply)
+		if err != nil {
+			return err
+		}
+		winRate := 0.0
+		if board.ToMove == record.Winner {
+			winRate = 1.0
+		}
+		book.Add(board, record.Moves[ply], winRate, 1)
+	}
+	return nil
+}
+
+// One move along a line through the book.
+type bookStep struct {
+	entry *BookEntry
+	move NaiveSpot
+}
+
+// Looks for the most promising position after b that isn't in the book
+// yet, trying the moves with the best win rates first and falling back
+// to the next best once everything after a move is complete. Adds the
+// steps to it onto line and moves b there. Returns false, leaving b and
+// line alone, if every line from b reaches MaxPly or the end of the
+// game. complete remembers the positions known to have nothing left.
+func (book *OpeningBook) frontier(b *NaiveBoard, line *[]bookStep,
+	complete map[int64]bool) bool {
+	if b.Winner() != Empty || len(*line) >= book.MaxPly {
+		return false
+	}
+	key, sym := canonicalKey(b)
+	if complete[key] {
+		return false
+	}
+	entry := book.Positions[key]
+	if entry == nil || len(entry.Moves) == 0 {
+		return true
+	}
+
+	moves := make([]BookMove, len(entry.Moves))
+	copy(moves, entry.Moves)
+	sort.SliceStable(moves, func(i, j int) bool {
+		return moves[i].WinRate > moves[j].WinRate
+	})
+	for _, move := range moves {
+		child := b.ToNaiveBoard()
+		child.MakeMove(sym.spot(move.Move))
+		*line = append(*line, bookStep{entry, move.Move})
+		if book.frontier(child, line, complete) {
+			*b = *child
+			return true
+		}
+		*line = (*line)[:len(*line) - 1]
+	}
+	complete[key] = true
+	return false
+}
+
+// Grows the book by analyzing one new position, the most promising one
+// not in the book yet, keeping the top moves of the analysis. Returns
+// false if there is nothing left to analyze, because every line
+// reaches MaxPly or the end of the game.
+func (book *OpeningBook) Expand(analyzer Analyzer, start *NaiveBoard,
+	top int) bool {
+	b := start.ToNaiveBoard()
+	line := make([]bookStep, 0)
+	if !book.frontier(b, &line, make(map[int64]bool)) {
+		return false
+	}
+
+	analysis := analyzer.Analyze(b).Top(top)
+	for _, move := range analysis.Moves {
+		book.Add(b, move.Move, move.WinRate, move.Playouts)
+	}
+
+	// Back up the new value along the line. The value of a move is the
+	// opposite of the best win rate after it.
+	winRate := analysis.WinRate
+	for i := len(line) - 1; i >= 0; i-- {
+		for j, move := range line[i].entry.Moves {
+			if move.Move == line[i].move {
+				line[i].entry.Moves[j].WinRate = 1.0 - winRate
+			}
+		}
+		best, _ := line[i].entry.Best(0)
+		winRate = best.WinRate
+	}
+	return true
+}
+
+func ReadOpeningBook(path string) (*OpeningBook, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	book := &OpeningBook{}
+	err = json.Unmarshal(content, book)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if book.Positions == nil {
+		book.Positions = make(map[int64]*BookEntry)
+	}
+	return book, nil
+}
+
+func (book *OpeningBook) Write(path string) error {
+	return ioutil.WriteFile(path, []byte(ToJSON(book)), 0644)
+}
+
+// Books are shared between players, since a tournament makes a new
+// player for every game.
+var bookCache = make(map[string]*OpeningBook)
+var bookCacheMutex sync.Mutex
+
+func loadOpeningBook(path string) (*OpeningBook, error) {
+	bookCacheMutex.Lock()
+	defer bookCacheMutex.Unlock()
+	book, ok := bookCache[path]
+	if ok {
+		return book, nil
+	}
+	book, err := ReadOpeningBook(path)
+	if err != nil {
+		return nil, err
+	}
+	bookCache[path] = book
+	return book, nil
+}
+
+// A BookPlayer plays from the book instantly while it can, and
+// otherwise asks another player.
+type BookPlayer struct {
+	Book *OpeningBook
+	Fallback Player
+
+	// Book moves with less evidence than this are ignored
+	MinWeight int
+
+	Quiet bool
+}
+
+func init() {
+	RegisterPlayerType(PlayerType{
+		Name: "book",
+		Help: "plays from an opening book, then hands off to another player",
+		Options: []PlayerOption{
+			{"file", "book.json", "the opening book, from build_book.go"},
+			{"minweight", "1", "ignore book moves with less evidence"},
+			{"then", "mcts5", "the player to use out of book"},
+			{"quiet", "false", "whether to skip logging"},
+		},
+		Make: func(o *PlayerOptions) Player {
+			book, err := loadOpeningBook(o.String("file"))
+			if err != nil {
+				o.fail("file", err)
+			}
+			return &BookPlayer{
+				Book: book,
+				Fallback: o.Player("then"),
+				MinWeight: o.Int("minweight"),
+				Quiet: o.Bool("quiet"),
+			}
+		},
+	})
+}
+
+func (p *BookPlayer) SetSeconds(seconds float64) {
+	timed, ok := p.Fallback.(TimedPlayer)
+	if ok {
+		timed.SetSeconds(seconds)
+	}
+}
+
+// The book has no limits, randomness or pondering of its own, so these
+// are the fallback's.
+func (p *BookPlayer) Limits() SearchLimits {
+	limited, ok := p.Fallback.(LimitedPlayer)
+	if !ok {
+		return SearchLimits{}
+	}
+	return limited.Limits()
+}
+
+func (p *BookPlayer) SetLimits(limits SearchLimits) {
+	limited, ok := p.Fallback.(LimitedPlayer)
+	if ok {
+		limited.SetLimits(limits)
+	}
+}
+
+func (p *BookPlayer) SetSeed(seed int64) {
+	seeded, ok := p.Fallback.(SeededPlayer)
+	if ok {
+		seeded.SetSeed(seed)
+	}
+}
+
+func (p *BookPlayer) StartPondering(b Board) {
+	ponderer, ok := p.Fallback.(Ponderer)
+	if ok {
+		ponderer.StartPondering(b)
+	}
+}
+
+// The fallback might be pondering since its last move, even if this
+// move came from the book.
+func (p *BookPlayer) StopPondering() {
+	StopPondering(p.Fallback)
+}
+
+func (p *BookPlayer) Play(b Board) (NaiveSpot, float64) {
+	entry, sym := p.Book.Lookup(b)
+	if entry != nil {
+		best, ok := entry.Best(p.MinWeight)
+		move := sym.spot(best.Move)
+		if ok && b.Get(move) == Empty {
+			if !p.Quiet {
+				log.Printf("book move %s, win rate %.3f", move, best.WinRate)
+			}
+			return move, best.WinRate
+		}
+	}
+	return p.Fallback.Play(b)
+}
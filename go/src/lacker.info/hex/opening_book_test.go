@@ -0,0 +1,214 @@
+package hex
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCanonicalKeySymmetry(t *testing.T) {
+	b := NewNaiveBoard()
+	b.MakeMove(MakeNaiveSpot(2, 3))
+	b.MakeMove(MakeNaiveSpot(5, 7))
+	b.MakeMove(MakeNaiveSpot(9, 1))
+	key, _ := canonicalKey(b)
+
+	for _, sym := range symmetries {
+		other := NewNaiveBoard()
+		other.ToMove = sym.color(b.ToMove)
+		for _, spot := range AllSpots() {
+			other.Set(sym.spot(spot), sym.color(b.Get(spot)))
+		}
+		otherKey, _ := canonicalKey(other)
+		if otherKey != key {
+			t.Fatalf("symmetry %d changed the key", sym)
+		}
+	}
+
+	b.MakeMove(MakeNaiveSpot(0, 0))
+	moved, _ := canonicalKey(b)
+	if moved == key {
+		t.Fatalf("a different position got the same key")
+	}
+}
+
+func TestBookImportAndPlay(t *testing.T) {
+	move := MakeNaiveSpot(3, 8)
+	book := NewOpeningBook(4)
+	err := book.Import(GameRecord{
+		Black: "a",
+		White: "b",
+		Moves: []NaiveSpot{move, MakeNaiveSpot(4, 4)},
+		Winner: Black,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(book.Positions) != 2 {
+		t.Fatalf("expected 2 positions but got %d", len(book.Positions))
+	}
+
+	player := &BookPlayer{Book: book, Fallback: GetPlayer("random"),
+		MinWeight: 1, Quiet: true}
+	spot, winRate := player.Play(NewNaiveBoard())
+	if spot != move || winRate != 1.0 {
+		t.Fatalf("expected the book move %s but got %s at %.3f", move, spot,
+			winRate)
+	}
+
+	// The mirror image of the first move is in the book too
+	mirror := NewNaiveBoard()
+	mirror.MakeMove(halfTurn.spot(move))
+	entry, _ := book.Lookup(mirror)
+	if entry == nil {
+		t.Fatalf("the half turn of a book position should be in the book")
+	}
+
+	// Out of book, the fallback plays
+	b := NewNaiveBoard()
+	b.MakeMove(MakeNaiveSpot(0, 0))
+	entry, _ = book.Lookup(b)
+	if entry != nil {
+		t.Fatalf("this position should not be in the book")
+	}
+	spot, _ = player.Play(b)
+	if b.Get(spot) != Empty {
+		t.Fatalf("the fallback made an illegal move %s", spot)
+	}
+}
+
+func TestBookExpand(t *testing.T) {
+	analyzer := GetPlayer(
+		"mcts:seconds=0,playouts=2000,seed=1,quiet=true").(Analyzer)
+	book := NewOpeningBook(2)
+	start := PuzzleMap["onePly"].Board
+	for i := 0; i < 3; i++ {
+		book.Expand(analyzer, start, 3)
+	}
+	entry, sym := book.Lookup(start)
+	if entry == nil {
+		t.Fatalf("the start position should be in the book")
+	}
+	best, ok := entry.Best(0)
+	if !ok || sym.spot(best.Move) != MakeNaiveSpot(10, 0) {
+		t.Fatalf("the book should know the winning move, not %+v", best)
+	}
+}
+
+// Analyzes every position the same way: the first empty spots are the
+// best moves, in order. Off the empty board the player to move is
+// losing, so the first move stays the best one.
+type firstSpotsAnalyzer struct{}
+
+func (a firstSpotsAnalyzer) Play(b Board) (NaiveSpot, float64) {
+	analysis := a.Analyze(b)
+	return analysis.Move, analysis.WinRate
+}
+
+func (a firstSpotsAnalyzer) Analyze(b Board) Analysis {
+	analysis := Analysis{Moves: make([]MoveAnalysis, 0)}
+	best := 0.6
+	if len(b.PossibleMoves()) < NumSpots {
+		best = 0.3
+	}
+	for i, move := range b.PossibleMoves()[:3] {
+		analysis.Moves = append(analysis.Moves, MoveAnalysis{
+			Move: move.NaiveSpot(),
+			WinRate: best - 0.1 * float64(i),
+			Playouts: 100,
+		})
+	}
+	analysis.Move = analysis.Moves[0].Move
+	analysis.WinRate = analysis.Moves[0].WinRate
+	return analysis
+}
+
+func TestBookExpandsEveryLine(t *testing.T) {
+	book := NewOpeningBook(2)
+	expansions := 0
+	for book.Expand(firstSpotsAnalyzer{}, NewNaiveBoard(), 2) {
+		expansions++
+		if expansions > 10 {
+			t.Fatalf("the book should be complete by now")
+		}
+	}
+
+	// The start, then the position after each of its two book moves.
+	// The first move stays the best even once its line reaches MaxPly,
+	// so the second one only gets expanded if complete lines are skipped.
+	if expansions != 3 || len(book.Positions) != 3 {
+		t.Fatalf("expected 3 expansions and positions but got %d and %d",
+			expansions, len(book.Positions))
+	}
+	entry, _ := book.Lookup(NewNaiveBoard())
+	best, _ := entry.Best(0)
+	if best.WinRate != 0.7 {
+		t.Fatalf("the first move should be backed up to 0.7, not %+v", best)
+	}
+}
+
+func TestBookPlayerSpec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "book")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "book.json")
+	err = NewOpeningBook(4).Write(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	player, err := LookupPlayer("book:file=" + path +
+		",then=mcts:seconds=0.5,quiet=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fallback, ok := player.(*BookPlayer).Fallback.(*MonteCarloTreeSearch)
+	if !ok || fallback.Seconds != 0.5 || !fallback.Quiet {
+		t.Fatalf("the nested spec was not parsed: %+v", fallback)
+	}
+
+	_, err = LookupPlayer("book:file=" + path + ",then=nonsense")
+	if err == nil {
+		t.Fatalf("a bad nested spec should fail")
+	}
+}
+
+// A book written by another run. Its keys only match if zobrist hashes
+// are the same in every run.
+const bookFixture = `{"Positions":{"25415776841370218":{"Moves":[` +
+	`{"Move":{"Row":3,"Col":4},"WinRate":0.75,"Weight":12}]}},"MaxPly":4}`
+
+func TestBookFileFixture(t *testing.T) {
+	b := NewNaiveBoard()
+	b.MakeMove(MakeNaiveSpot(3, 4))
+	key, _ := canonicalKey(b)
+	if key != 25415776841370218 {
+		t.Fatalf("the key for this position changed to %d", key)
+	}
+
+	dir, err := ioutil.TempDir("", "book")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "book.json")
+	err = ioutil.WriteFile(path, []byte(bookFixture), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	book, err := ReadOpeningBook(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, sym := book.Lookup(b)
+	if entry == nil {
+		t.Fatalf("the position should be in the book")
+	}
+	best, _ := entry.Best(0)
+	if sym.spot(best.Move) != MakeNaiveSpot(7, 6) {
+		t.Fatalf("expected the book move (7, 6) but got %s", sym.spot(best.Move))
+	}
+}
@@ -0,0 +1,589 @@
+package hex
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+An Oracle knows who wins every position on a small board with perfect
+play, so it can check whether a player's move wins and whether its
+win rate estimates mean anything.
+
+Up to 4x4 the oracle keeps a database with an entry for every way of
+filling the cells with Empty, Black and White. The index of a position
+is its cells as a number in base 3, with (0, 0) as the lowest digit.
+Whose turn it is follows from the number of stones, since Black moves
+first, so each entry just needs two bits: unknown, a win for the player
+to move, or a loss. The database is filled by a depth-first search of
+every position reachable from the empty board, and saved gzipped.
+
+There are 3^25 ways to fill a 5x5 board, which would take over 200GB
+at two bits each, and the solver takes minutes just to prove every
+position with two stones. So on 5x5 the oracle instead proves each
+position it's asked about with the Solver, and remembers the answers
+along with every position the Solver proved on the way. Those are
+saved as a sorted list of zobrist keys, eight bytes each, followed by
+a byte for whether the player to move wins, gzipped like the full
+database. Building a 5x5 database proves the empty board and every
+position after the first move, so it always knows the value of every
+opening move without solving anything, and it grows with whatever else
+the oracle gets asked before it's written out again.
+*/
+
+// The largest board an oracle works on. The Solver can't prove the
+// empty 6x6 board in reasonable time.
+const MaxOracleSize = 5
+
+// The largest board that gets a full database.
+const MaxDatabaseSize = 4
+
+const (
+	oracleUnknown byte = iota
+	oracleWin
+	oracleLoss
+)
+
+type Oracle struct {
+	Size int
+
+	// Two bits per position, when there is a database
+	data []byte
+	powers []int
+
+	// For boards too big for the database, keyed by zobrist hash
+	cache map[int64]bool
+	solver Solver
+
+	mutex sync.Mutex
+}
+
+func NewOracle(size int) (*Oracle, error) {
+	if size < 1 || size > MaxOracleSize {
+		return nil, fmt.Errorf("the oracle works on sizes 1 to %d, not %d",
+			MaxOracleSize, size)
+	}
+	o := &Oracle{
+		Size: size,
+		cache: make(map[int64]bool),
+		solver: Solver{KeepTable: true},
+	}
+	if size <= MaxDatabaseSize {
+		o.powers = make([]int, size * size)
+		positions := 1
+		for i := range o.powers {
+			o.powers[i] = positions
+			positions *= 3
+		}
+		o.data = make([]byte, (positions + 3) / 4)
+	}
+	return o, nil
+}
+
+func (o *Oracle) get(index int) byte {
+	return (o.data[index / 4] >> uint(2 * (index % 4))) & 3
+}
+
+func (o *Oracle) set(index int, value byte) {
+	o.data[index / 4] |= value << uint(2 * (index % 4))
+}
+
+func (o *Oracle) digit(color Color) int {
+	switch color {
+	case Black:
+		return 1
+	case White:
+		return 2
+	}
+	return 0
+}
+
+// Whether color connects its sides. The cells are row by row.
+func (o *Oracle) connects(cells []Color, color Color) bool {
+	n := o.Size
+	seen := make([]bool, len(cells))
+	stack := make([]int, 0)
+	for i := 0; i < n; i++ {
+		start := i
+		if color == White {
+			start = i * n
+		}
+		if cells[start] == color {
+			seen[start] = true
+			stack = append(stack, start)
+		}
+	}
+	for len(stack) > 0 {
+		cell := stack[len(stack) - 1]
+		stack = stack[:len(stack) - 1]
+		r, c := cell / n, cell % n
+		if (color == Black && r == n - 1) || (color == White && c == n - 1) {
+			return true
+		}
+		for _, d := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}, {1, -1},
+			{-1, 1}} {
+			nr, nc := r + d[0], c + d[1]
+			if nr < 0 || nr >= n || nc < 0 || nc >= n {
+				continue
+			}
+			next := nr * n + nc
+			if !seen[next] && cells[next] == color {
+				seen[next] = true
+				stack = append(stack, next)
+			}
+		}
+	}
+	return false
+}
+
+// Whether the player to move wins, for a position nobody has won yet.
+func (o *Oracle) search(cells []Color, index int, toMove Color) bool {
+	value := o.get(index)
+	if value != oracleUnknown {
+		return value == oracleWin
+	}
+	wins := false
+	for i, cell := range cells {
+		if cell != Empty {
+			continue
+		}
+		cells[i] = toMove
+		if o.connects(cells, toMove) ||
+			!o.search(cells, index + o.powers[i] * o.digit(toMove), -toMove) {
+			wins = true
+		}
+		cells[i] = Empty
+		if wins {
+			break
+		}
+	}
+	if wins {
+		o.set(index, oracleWin)
+	} else {
+		o.set(index, oracleLoss)
+	}
+	return wins
+}
+
+// Fills in the whole database. Without a full database, proves the
+// empty board and every position after the first move instead.
+func (o *Oracle) Build() {
+	start := NewSmallBoard(o.Size)
+	o.Wins(start)
+	if o.data == nil {
+		o.WinningMoves(start)
+	}
+}
+
+// Whether the player to move wins the position with perfect play.
+func (o *Oracle) Wins(board Board) bool {
+	b := board.ToNaiveBoard()
+	winner := b.Winner()
+	if winner != Empty {
+		return winner == b.ToMove
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.data != nil {
+		cells := make([]Color, o.Size * o.Size)
+		index := 0
+		stones := 0
+		for r := 0; r < o.Size; r++ {
+			for c := 0; c < o.Size; c++ {
+				i := r * o.Size + c
+				cells[i] = b.Get(MakeNaiveSpot(r, c))
+				index += o.powers[i] * o.digit(cells[i])
+				if cells[i] == Black {
+					stones++
+				} else if cells[i] == White {
+					stones--
+				}
+			}
+		}
+		if (stones == 0 && b.ToMove == Black) ||
+			(stones == 1 && b.ToMove == White) {
+			return o.search(cells, index, b.ToMove)
+		}
+		// Positions that can't come up in a game aren't in the database
+	}
+
+	key := b.ToTopoBoard().Zobrist()
+	wins, ok := o.cache[key]
+	if !ok {
+		result := o.solver.Solve(b)
+		wins = result.Winner == b.ToMove
+		o.cache[key] = wins
+	}
+	return wins
+}
+
+// All the moves that keep a win for the player to move. Empty if they
+// are lost.
+func (o *Oracle) WinningMoves(board Board) []NaiveSpot {
+	b := board.ToNaiveBoard()
+	answer := make([]NaiveSpot, 0)
+	for _, move := range b.PossibleMoves() {
+		child := b.ToNaiveBoard()
+		child.MakeMove(move)
+		if !o.Wins(child) {
+			answer = append(answer, move)
+		}
+	}
+	return answer
+}
+
+// Every position proven without the full database, by zobrist key,
+// including the ones the solver proved along the way.
+func (o *Oracle) provenPositions() map[int64]bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	answer := make(map[int64]bool)
+	for key, entry := range o.solver.table {
+		if entry.phi == 0 {
+			answer[key] = true
+		} else if entry.delta == 0 {
+			answer[key] = false
+		}
+	}
+	for key, wins := range o.cache {
+		answer[key] = wins
+	}
+	return answer
+}
+
+// Counts the positions the database has values for.
+func (o *Oracle) NumPositions() int {
+	if o.data == nil {
+		return len(o.provenPositions())
+	}
+	answer := 0
+	for index := 0; index < len(o.data) * 4; index++ {
+		if o.get(index) != oracleUnknown {
+			answer++
+		}
+	}
+	return answer
+}
+
+func (o *Oracle) Write(path string) error {
+	content := append([]byte{byte(o.Size)}, o.data...)
+	if o.data == nil {
+		proven := o.provenPositions()
+		keys := make([]int64, 0, len(proven))
+		for key := range proven {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		entry := make([]byte, 9)
+		for _, key := range keys {
+			binary.BigEndian.PutUint64(entry, uint64(key))
+			entry[8] = oracleLoss
+			if proven[key] {
+				entry[8] = oracleWin
+			}
+			content = append(content, entry...)
+		}
+	}
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	_, err := writer.Write(content)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buffer.Bytes(), 0644)
+}
+
+func ReadOracle(path string) (*Oracle, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	content, err = ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if len(content) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	o, err := NewOracle(int(content[0]))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if o.data == nil {
+		entries := content[1:]
+		if len(entries) % 9 != 0 {
+			return nil, fmt.Errorf("%s has a partial entry", path)
+		}
+		for i := 0; i < len(entries); i += 9 {
+			key := int64(binary.BigEndian.Uint64(entries[i:i + 8]))
+			o.cache[key] = entries[i + 8] == oracleWin
+		}
+		return o, nil
+	}
+	if len(content) - 1 != len(o.data) {
+		return nil, fmt.Errorf("%s has the wrong length for size %d", path,
+			o.Size)
+	}
+	o.data = content[1:]
+	return o, nil
+}
+
+// The size of the small board that b is, or 0 if it isn't one. The
+// full board counts as size BoardSize.
+func SmallBoardSize(b *NaiveBoard) int {
+	for size := 1; size <= BoardSize; size++ {
+		filled := true
+		for _, spot := range AllSpots() {
+			if (spot.Row() >= size && b.Get(spot) != Black) ||
+				(spot.Row() < size && spot.Col() >= size &&
+				b.Get(spot) != White) {
+				filled = false
+				break
+			}
+		}
+		if filled {
+			return size
+		}
+	}
+	return 0
+}
+
+// Oracles are shared between players, one per size.
+var oracles = make(map[int]*Oracle)
+var oraclesMutex sync.Mutex
+
+// Gets the oracle for a size, loading it from file if there is one and
+// it's for that size.
+func loadOracle(size int, file string) (*Oracle, error) {
+	oraclesMutex.Lock()
+	defer oraclesMutex.Unlock()
+	o, ok := oracles[size]
+	if ok {
+		return o, nil
+	}
+	var err error
+	if file != "" {
+		o, err = ReadOracle(file)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if o == nil || o.Size != size {
+		o, err = NewOracle(size)
+		if err != nil {
+			return nil, err
+		}
+	}
+	oracles[size] = o
+	return o, nil
+}
+
+// An OraclePlayer plays perfectly on small boards. When it's lost, it
+// plays the move that makes the opponent's winning moves the fewest.
+type OraclePlayer struct {
+	SeededRand
+
+	// A database to start from, for its size
+	File string
+
+	// The player to use on boards too big for the oracle
+	Fallback Player
+}
+
+func init() {
+	RegisterPlayerType(PlayerType{
+		Name: "oracle",
+		Help: "plays perfectly on boards up to 5x5",
+		Options: []PlayerOption{
+			{"file", "", "a database from oracle.go, to avoid rebuilding it"},
+			{"then", "random", "the player to use on bigger boards"},
+		},
+		Make: func(o *PlayerOptions) Player {
+			return &OraclePlayer{
+				File: o.String("file"),
+				Fallback: o.Player("then"),
+			}
+		},
+	})
+}
+
+// Seeds the choice between winning moves, and the fallback.
+func (p *OraclePlayer) SetSeed(seed int64) {
+	p.SeededRand.SetSeed(seed)
+	seeded, ok := p.Fallback.(SeededPlayer)
+	if ok {
+		seeded.SetSeed(seed)
+	}
+}
+
+func (p *OraclePlayer) Play(board Board) (NaiveSpot, float64) {
+	b := board.ToNaiveBoard()
+	size := SmallBoardSize(b)
+	if size == 0 || size > MaxOracleSize {
+		return p.Fallback.Play(board)
+	}
+	o, err := loadOracle(size, p.File)
+	if err != nil {
+		log.Fatal(err)
+	}
+	winning := o.WinningMoves(b)
+	if len(winning) > 0 {
+		return winning[p.Rand().Intn(len(winning))], 1.0
+	}
+
+	var best NaiveSpot
+	fewest := -1
+	for _, move := range b.PossibleMoves() {
+		child := b.ToNaiveBoard()
+		child.MakeMove(move)
+		replies := len(o.WinningMoves(child))
+		if fewest < 0 || replies < fewest {
+			best = move
+			fewest = replies
+		}
+	}
+	return best, 0.0
+}
+
+// Tracks how often a player's win rates come true, in ten buckets.
+type Calibration struct {
+	Predictions [10]int
+	Wins [10]int
+	TotalPredicted [10]float64
+}
+
+func (c *Calibration) Add(winRate float64, won bool) {
+	bucket := int(winRate * 10)
+	if bucket < 0 {
+		bucket = 0
+	}
+	if bucket > 9 {
+		bucket = 9
+	}
+	c.Predictions[bucket]++
+	c.TotalPredicted[bucket] += winRate
+	if won {
+		c.Wins[bucket]++
+	}
+}
+
+// The average gap between predicted and actual win rates, weighted by
+// the number of predictions in each bucket.
+func (c *Calibration) Error() float64 {
+	total := 0
+	gap := 0.0
+	for i, n := range c.Predictions {
+		if n == 0 {
+			continue
+		}
+		predicted := c.TotalPredicted[i] / float64(n)
+		actual := float64(c.Wins[i]) / float64(n)
+		diff := predicted - actual
+		if diff < 0 {
+			diff = -diff
+		}
+		gap += diff * float64(n)
+		total += n
+	}
+	if total == 0 {
+		return 0.0
+	}
+	return gap / float64(total)
+}
+
+func (c *Calibration) String() string {
+	lines := make([]string, 0)
+	for i, n := range c.Predictions {
+		if n == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  %.1f-%.1f: %4d predictions, " +
+			"average %.3f, actually won %.3f", float64(i) / 10,
+			float64(i + 1) / 10, n, c.TotalPredicted[i] / float64(n),
+			float64(c.Wins[i]) / float64(n)))
+	}
+	lines = append(lines, fmt.Sprintf("  calibration error %.3f", c.Error()))
+	return strings.Join(lines, "\n")
+}
+
+// How well a player does against the oracle.
+type OracleScore struct {
+	Positions int
+
+	// Positions where the player to move can win, and how many times
+	// the player found a winning move in them
+	Winnable int
+	Found int
+
+	Calibration Calibration
+}
+
+func (s *OracleScore) String() string {
+	accuracy := 0.0
+	if s.Winnable > 0 {
+		accuracy = float64(s.Found) / float64(s.Winnable)
+	}
+	return fmt.Sprintf("found a winning move in %d of %d winnable " +
+		"positions (%.3f), out of %d\n%s", s.Found, s.Winnable, accuracy,
+		s.Positions, s.Calibration.String())
+}
+
+// Random positions from games of random moves, with at most maxStones
+// stones and no winner yet.
+func RandomPositions(size int, count int, maxStones int) []*NaiveBoard {
+	answer := make([]*NaiveBoard, 0)
+	for len(answer) < count {
+		b := NewSmallBoard(size)
+		stones := rand.Intn(maxStones + 1)
+		for i := 0; i < stones; i++ {
+			moves := b.PossibleMoves()
+			b.MakeMove(moves[rand.Intn(len(moves))])
+		}
+		if b.Winner() == Empty {
+			answer = append(answer, b)
+		}
+	}
+	return answer
+}
+
+// Asks the player to move in every position, and checks its moves and
+// win rates against the oracle.
+func (o *Oracle) Score(player Player, positions []*NaiveBoard) *OracleScore {
+	score := &OracleScore{}
+	for _, b := range positions {
+		score.Positions++
+		wins := o.Wins(b)
+		move, winRate := player.Play(b.ToNaiveBoard())
+		score.Calibration.Add(winRate, wins)
+		if !wins {
+			continue
+		}
+		score.Winnable++
+		if move.IsNotASpot() || b.Get(move) != Empty {
+			continue
+		}
+		child := b.ToNaiveBoard()
+		child.MakeMove(move)
+		if !o.Wins(child) {
+			score.Found++
+		}
+	}
+	return score
+}
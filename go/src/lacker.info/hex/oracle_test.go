@@ -0,0 +1,164 @@
+package hex
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOracleMatchesSolver(t *testing.T) {
+	for size := 1; size <= 4; size++ {
+		o, err := NewOracle(size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Black always wins the empty board
+		if !o.Wins(NewSmallBoard(size)) {
+			t.Fatalf("Black should win the empty %dx%d board", size, size)
+		}
+		for _, b := range RandomPositions(size, 30, size * size - 1) {
+			solver := Solver{}
+			result := solver.Solve(b)
+			if o.Wins(b) != (result.Winner == b.ToMove) {
+				b.Eprint()
+				t.Fatalf("the oracle and the solver disagree")
+			}
+		}
+	}
+}
+
+func TestOracleFile(t *testing.T) {
+	o, _ := NewOracle(3)
+	o.Build()
+	dir, err := ioutil.TempDir("", "oracle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "oracle3.db")
+	err = o.Write(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ReadOracle(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Size != 3 || loaded.NumPositions() != o.NumPositions() {
+		t.Fatalf("expected %d positions but loaded %d", o.NumPositions(),
+			loaded.NumPositions())
+	}
+
+	// 3x3 is won by playing on the short diagonal, or in the middle of
+	// White's sides
+	moves := loaded.WinningMoves(NewSmallBoard(3))
+	if len(moves) != 5 {
+		t.Fatalf("expected 5 winning moves but got %v", moves)
+	}
+}
+
+func TestOraclePlayer(t *testing.T) {
+	player := GetPlayer("oracle")
+	o, _ := NewOracle(4)
+	positions := RandomPositions(4, 50, 10)
+	score := o.Score(player, positions)
+	if score.Found != score.Winnable || score.Calibration.Error() != 0.0 {
+		t.Fatalf("the oracle should be perfect: %s", score)
+	}
+
+	if SmallBoardSize(NewNaiveBoard()) != BoardSize ||
+		SmallBoardSize(NewSmallBoard(4)) != 4 {
+		t.Fatalf("bad SmallBoardSize")
+	}
+
+	// Too big for the oracle, so it falls back to random
+	b := NewNaiveBoard()
+	move, _ := player.Play(b)
+	if b.Get(move) != Empty {
+		t.Fatalf("the fallback made an illegal move")
+	}
+}
+
+func TestCalibration(t *testing.T) {
+	c := Calibration{}
+	c.Add(0.75, true)
+	c.Add(0.75, false)
+	if c.Error() != 0.25 {
+		t.Fatalf("expected an error of 0.25 but got %.3f", c.Error())
+	}
+}
+
+// The small puzzles that ship with the repo should list exactly the
+// moves that win.
+func TestPuzzleFilesMatchOracle(t *testing.T) {
+	puzzles, err := LoadPuzzleDir("../puzzles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, puzzle := range puzzles {
+		if puzzle.Type != DefiniteWin || puzzle.Size > 4 {
+			continue
+		}
+		o, err := NewOracle(puzzle.Size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		winning := o.WinningMoves(puzzle.Board)
+		for _, move := range winning {
+			if !puzzle.IsAnswer(move) {
+				t.Fatalf("%s: %s also wins", name, move)
+			}
+		}
+		if len(winning) != len(puzzle.Answers) {
+			t.Fatalf("%s: %v win but the answers are %v",
+				name, winning, puzzle.Answers)
+		}
+	}
+}
+
+func TestOracleFile5(t *testing.T) {
+	o, _ := NewOracle(5)
+	start := NewSmallBoard(5)
+	if !o.Wins(start) {
+		t.Fatalf("Black should win the empty 5x5 board")
+	}
+	dir, err := ioutil.TempDir("", "oracle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "oracle5.db")
+	err = o.Write(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ReadOracle(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Everything the solver proved comes along, not just the empty board
+	if loaded.Size != 5 || loaded.NumPositions() != o.NumPositions() ||
+		loaded.NumPositions() < 1000 {
+		t.Fatalf("expected %d positions but loaded %d", o.NumPositions(),
+			loaded.NumPositions())
+	}
+	if !loaded.Wins(start) || loaded.solver.table != nil {
+		t.Fatalf("the loaded oracle should know the empty board without solving")
+	}
+
+	// A player with the file uses it on 5x5 and ignores it on 4x4
+	delete(oracles, 5)
+	delete(oracles, 4)
+	defer delete(oracles, 5)
+	defer delete(oracles, 4)
+	o5, err := loadOracle(5, path)
+	if err != nil || o5.NumPositions() != loaded.NumPositions() {
+		t.Fatalf("the 5x5 oracle should come from the file: %v", err)
+	}
+	o4, err := loadOracle(4, path)
+	if err != nil || o4.Size != 4 {
+		t.Fatalf("the 4x4 oracle should not come from a 5x5 file: %v", err)
+	}
+}
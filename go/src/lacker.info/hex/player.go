@@ -14,38 +14,75 @@ type Player interface {
 	Play(b Board) (NaiveSpot, float64)
 }
 
+// A TimedPlayer is a Player whose thinking time can be changed
+// between moves.
+type TimedPlayer interface {
+	Player
+	SetSeconds(seconds float64)
+}
+
+// A Ponderer can think while the opponent is to move, so that its
+// next move starts from what it found.
+type Ponderer interface {
+	Player
+
+	// Thinks about b in the background, which is usually the position
+	// after our move. Pondering goes on until StopPondering or the next
+	// move.
+	StartPondering(b Board)
+
+	// Returns once pondering has stopped. It's fine to call this when
+	// not pondering.
+	StopPondering()
+}
+
+// Stops the player pondering, if it can ponder at all. Anything that
+// makes players should call this once it's done with them, since a
+// pondering player would otherwise keep searching in the background.
+func StopPondering(player Player) {
+	ponderer, ok := player.(Ponderer)
+	if ok {
+		ponderer.StopPondering()
+	}
+}
+
 func GetPlayer(s string) Player {
-	switch s {
-	case "random":
-		return Random{}
-	case "sr1":
-		return ShallowRave{Seconds:1, Quiet:false}
-	case "sr5":
-		return ShallowRave{Seconds:5, Quiet:false}
-	case "sr20":
-		return ShallowRave{Seconds:20, Quiet:false}
-	case "topo5":
-		mcts := MakeMCTS(5)
-		mcts.UseTopoBoards = true
-		return mcts
-	case "mcts1":
-		return MakeMCTS(1)
-	case "mcts5":
-		return MakeMCTS(5)
-	case "mcts20":
-		return MakeMCTS(20)
-	case "ss5":
-		return SpotSorter{Seconds:5, Quiet:false}
-	case "mf5":
-		return MetaFarmer{Seconds:5, Quiet:false, QuickType:"democracy"}
-	case "dn5":
-		return MetaFarmer{Seconds:5, Quiet:false, QuickType:"deltanet"}
-	case "qt":
-		return &QTrainer{Seconds:5, Quiet:false}
-	default:
-		log.Fatalf("unknown player type: %s", s)
-		return nil
+	player, err := LookupPlayer(s)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return player
+}
+
+// Like GetPlayer but returns an error for a bad player spec.
+// See player_spec.go for the syntax.
+func LookupPlayer(s string) (Player, error) {
+	name, values, err := ParsePlayerSpec(s)
+	if err != nil {
+		return nil, err
+	}
+	playerType, ok := playerTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown player type: %s", name)
+	}
+	options, err := playerType.options(values)
+	if err != nil {
+		return nil, err
+	}
+	player := playerType.Make(options)
+	seed := options.Int64("seed")
+	if seed != 0 {
+		seeded, ok := player.(SeededPlayer)
+		if ok {
+			seeded.SetSeed(seed)
+		} else {
+			options.fail("seed", fmt.Errorf("%s has no randomness", name))
+		}
+	}
+	if options.err != nil {
+		return nil, fmt.Errorf("%s: %s", s, options.err)
 	}
+	return player, nil
 }
 
 // Helper for script
@@ -0,0 +1,259 @@
+package hex
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+A player spec says what kind of player to make and how to set it up.
+It's a player type, optionally followed by a colon and comma-separated
+options, like:
+
+mcts
+mcts:seconds=3,v=500,topo=true
+mf:type=deltanet,seconds=10
+
+Options that aren't given get their defaults. Each player type
+registers itself with its options, and the old fixed names like mcts5
+are aliases for specs.
+
+Some players wrap another player, given by a spec as an option value.
+If that spec has options of its own, it has to come last, since it
+takes everything after it:
+
+book:file=book.json,then=mcts:seconds=3,v=500
+
+Player types with a seconds option also take playouts and nodes, as in
+search_limits.go. Every player type lists a seed option, but only the
+ones with randomness accept it: mcts, sr, qt, random, oracle, and book,
+which seeds its fallback. mf and ss make no random choices, so giving
+them a seed is an error.
+*/
+
+type PlayerOption struct {
+	Name string
+	Default string
+	Help string
+}
+
+type PlayerType struct {
+	Name string
+	Help string
+	Options []PlayerOption
+
+	// Makes a player from the options. Errors in the options are
+	// collected by the PlayerOptions.
+	Make func(options *PlayerOptions) Player
+}
+
+var playerTypes = make(map[string]*PlayerType)
+
+// The old player names, each standing for a spec.
+var playerAliases = map[string]string{
+	"sr1": "sr:seconds=1",
+	"sr5": "sr:seconds=5",
+	"sr20": "sr:seconds=20",
+	"topo5": "mcts:seconds=5,topo=true",
+	"mcts1": "mcts:seconds=1",
+	"mcts5": "mcts:seconds=5",
+	"mcts20": "mcts:seconds=20",
+	"ss5": "ss:seconds=5",
+	"mf5": "mf:type=democracy,seconds=5",
+	"dn5": "mf:type=deltanet,seconds=5",
+	"qt": "qt:seconds=5",
+}
+
+// Makes a player type available to GetPlayer. Player types register
+// themselves in init functions.
+func RegisterPlayerType(t PlayerType) {
+	_, ok := playerTypes[t.Name]
+	if ok {
+		panic("duplicate player type: " + t.Name)
+	}
+	options := make([]PlayerOption, 0)
+	for _, option := range t.Options {
+		options = append(options, option)
+		if option.Name == "seconds" {
+			options = append(options,
+				PlayerOption{"playouts", "0", "how many playouts to do per move"},
+				PlayerOption{"nodes", "0", "how many tree nodes to add per move"})
+		}
+	}
+	t.Options = append(options,
+		PlayerOption{"seed", "0", "seeds the randomness. 0 for a random seed"})
+	playerTypes[t.Name] = &t
+}
+
+// Splits a spec into its player type and option values, after
+// expanding any alias.
+func ParsePlayerSpec(spec string) (string, map[string]string, error) {
+	alias, ok := playerAliases[spec]
+	if ok {
+		spec = alias
+	}
+	values := make(map[string]string)
+	parts := strings.SplitN(spec, ":", 2)
+	name := parts[0]
+	if name == "" {
+		return "", nil, fmt.Errorf("bad player spec: %q", spec)
+	}
+	if len(parts) == 1 || parts[1] == "" {
+		return name, values, nil
+	}
+	options := strings.Split(parts[1], ",")
+	for i := 0; i < len(options); i++ {
+		kv := strings.SplitN(options[i], "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return "", nil, fmt.Errorf("bad option %q in player spec %s",
+				options[i], spec)
+		}
+		_, ok = values[kv[0]]
+		if ok {
+			return "", nil, fmt.Errorf("duplicate option %s in player spec %s",
+				kv[0], spec)
+		}
+		if strings.Contains(kv[1], ":") {
+			// A nested player spec takes the rest of the options
+			kv[1] = strings.Join(append([]string{kv[1]}, options[i + 1:]...), ",")
+			i = len(options)
+		}
+		values[kv[0]] = kv[1]
+	}
+	return name, values, nil
+}
+
+// Fills in defaults, and checks that every value is for a real option.
+func (t *PlayerType) options(values map[string]string) (*PlayerOptions,
+	error) {
+	options := &PlayerOptions{values: make(map[string]string)}
+	for _, option := range t.Options {
+		options.values[option.Name] = option.Default
+	}
+	for key, value := range values {
+		_, ok := options.values[key]
+		if !ok {
+			return nil, fmt.Errorf("%s has no option %s", t.Name, key)
+		}
+		options.values[key] = value
+	}
+	return options, nil
+}
+
+// The option values for making one player. The first bad value is
+// remembered, so a Make function can read all its options and let
+// LookupPlayer report the error.
+type PlayerOptions struct {
+	values map[string]string
+	err error
+}
+
+func (o *PlayerOptions) fail(name string, err error) {
+	if o.err == nil {
+		o.err = fmt.Errorf("bad value for %s: %s", name, err)
+	}
+}
+
+func (o *PlayerOptions) String(name string) string {
+	value, ok := o.values[name]
+	if !ok {
+		panic("unregistered option: " + name)
+	}
+	return value
+}
+
+func (o *PlayerOptions) Float(name string) float64 {
+	answer, err := strconv.ParseFloat(o.String(name), 64)
+	if err != nil {
+		o.fail(name, err)
+	}
+	return answer
+}
+
+func (o *PlayerOptions) Int(name string) int {
+	answer, err := strconv.Atoi(o.String(name))
+	if err != nil {
+		o.fail(name, err)
+	}
+	return answer
+}
+
+func (o *PlayerOptions) Int64(name string) int64 {
+	answer, err := strconv.ParseInt(o.String(name), 10, 64)
+	if err != nil {
+		o.fail(name, err)
+	}
+	return answer
+}
+
+// The seconds, playouts and nodes options.
+func (o *PlayerOptions) Limits() SearchLimits {
+	return SearchLimits{
+		Seconds: o.Float("seconds"),
+		Playouts: o.Int("playouts"),
+		Nodes: o.Int("nodes"),
+	}
+}
+
+func (o *PlayerOptions) Bool(name string) bool {
+	answer, err := strconv.ParseBool(o.String(name))
+	if err != nil {
+		o.fail(name, err)
+	}
+	return answer
+}
+
+// A string that must be one of the choices.
+func (o *PlayerOptions) Choice(name string, choices ...string) string {
+	value := o.String(name)
+	for _, choice := range choices {
+		if value == choice {
+			return value
+		}
+	}
+	o.fail(name, fmt.Errorf("%s is not one of %s", value,
+		strings.Join(choices, ", ")))
+	return value
+}
+
+// A player given by a nested spec.
+func (o *PlayerOptions) Player(name string) Player {
+	player, err := LookupPlayer(o.String(name))
+	if err != nil {
+		o.fail(name, err)
+	}
+	return player
+}
+
+// Describes every player type, its options, and the aliases.
+func PlayerHelp() string {
+	names := make([]string, 0)
+	for name := range playerTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0)
+	for _, name := range names {
+		t := playerTypes[name]
+		lines = append(lines, fmt.Sprintf("%s: %s", name, t.Help))
+		for _, option := range t.Options {
+			lines = append(lines, fmt.Sprintf("  %-8s %s (default %s)",
+				option.Name, option.Help, option.Default))
+		}
+	}
+
+	aliases := make([]string, 0)
+	for alias := range playerAliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	lines = append(lines, "", "aliases:")
+	for _, alias := range aliases {
+		lines = append(lines, fmt.Sprintf("  %-8s %s", alias,
+			playerAliases[alias]))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,69 @@
+package hex
+
+import (
+	"testing"
+)
+
+func TestLookupPlayerSpec(t *testing.T) {
+	player, err := LookupPlayer("mcts:seconds=3,v=500,topo=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mcts := player.(*MonteCarloTreeSearch)
+	if mcts.Seconds != 3 || mcts.V != 500 || !mcts.UseTopoBoards {
+		t.Fatalf("bad options: %+v", mcts)
+	}
+
+	player, err = LookupPlayer("mf:type=deltanet,seconds=10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mf := player.(*MetaFarmer)
+	if mf.Seconds != 10 || mf.QuickType != "deltanet" {
+		t.Fatalf("bad options: %+v", mf)
+	}
+
+	// Defaults
+	player, err = LookupPlayer("mcts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if player.(*MonteCarloTreeSearch).Seconds != 5 {
+		t.Fatalf("bad default seconds")
+	}
+}
+
+func TestPlayerAliases(t *testing.T) {
+	for alias := range playerAliases {
+		_, err := LookupPlayer(alias)
+		if err != nil {
+			t.Fatalf("%s: %s", alias, err)
+		}
+	}
+	player := GetPlayer("topo5").(*MonteCarloTreeSearch)
+	if player.Seconds != 5 || !player.UseTopoBoards || player.V != 1000 {
+		t.Fatalf("topo5 changed: %+v", player)
+	}
+	if GetPlayer("dn5").(*MetaFarmer).QuickType != "deltanet" {
+		t.Fatalf("dn5 changed")
+	}
+}
+
+func TestBadPlayerSpecs(t *testing.T) {
+	bad := []string{
+		"",
+		"nonsense",
+		"mcts:seconds",
+		"mcts:speed=3",
+		"mcts:seconds=fast",
+		"mcts:seconds=1,seconds=2",
+		"mf:type=other",
+		"mcts:topo=maybe",
+	}
+	for _, spec := range bad {
+		_, err := LookupPlayer(spec)
+		if err == nil {
+			t.Fatalf("expected an error for %q", spec)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package hex
+
+import (
+	"time"
+)
+
+/*
+Searching players can report on their progress while they think, so
+that a UI can show what they are considering in real time.
+Win rates are from the point of view of the player to move.
+*/
+
+type SearchProgress struct {
+	BestMove NaiveSpot
+	WinRate float64
+	Playouts int
+	Seconds float64
+
+	// The line of play the search currently expects, starting with
+	// BestMove. Players that only look one move ahead just report
+	// BestMove here.
+	PrincipalVariation []NaiveSpot
+}
+
+type SearchObserver interface {
+	ObserveSearch(progress SearchProgress)
+}
+
+// Lets a plain function act as a SearchObserver.
+type SearchObserverFunc func(progress SearchProgress)
+
+func (f SearchObserverFunc) ObserveSearch(progress SearchProgress) {
+	f(progress)
+}
+
+// An ObservablePlayer is a Player that reports its progress to an
+// observer while it thinks. Setting the observer to nil turns
+// reporting off.
+type ObservablePlayer interface {
+	Player
+	SetObserver(observer SearchObserver)
+}
+
+// How often, in seconds, searches report their progress.
+var ProgressInterval float64 = 0.25
+
+// Keeps track of when a search should next report its progress.
+type progressClock struct {
+	start time.Time
+	last time.Time
+}
+
+func newProgressClock() progressClock {
+	now := time.Now()
+	return progressClock{start: now, last: now}
+}
+
+// Whether it's time for another report. If so, the clock resets.
+func (c *progressClock) due(observer SearchObserver) bool {
+	if observer == nil || SecondsSince(c.last) < ProgressInterval {
+		return false
+	}
+	c.last = time.Now()
+	return true
+}
+
+func (c *progressClock) seconds() float64 {
+	return SecondsSince(c.start)
+}
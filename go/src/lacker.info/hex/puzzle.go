@@ -1,24 +1,50 @@
 package hex
 
 import (
+	"fmt"
 	"log"
-	"strings"
 )
 
 type Puzzle struct {
+	Name string
+
+	// The text the puzzle was made from
 	String string
+
 	Board *NaiveBoard
+	Type PuzzleType
+
+	// Any of these moves solves the puzzle. DefiniteLoss puzzles may
+	// have none.
+	Answers []NaiveSpot
+
+	// The first of the answers, if there are any
 	CorrectAnswer NaiveSpot
+
+	// The range the solver's win rate estimate should be in
+	MinWinRate float64
+	MaxWinRate float64
+
+	// Puzzles smaller than BoardSize are played in a corner of the
+	// board, as with NewSmallBoard.
+	Size int
+
+	Tags []string
+	Notes string
 }
 
 var PuzzleMap map[string]Puzzle = MakePuzzleMap()
 
 // Create the library of interesting puzzles.
+// See ParsePuzzle for the format. More puzzles can be loaded from files
+// with AddPuzzleDir.
 func MakePuzzleMap() map[string]Puzzle {
 	puzzleMap := make(map[string]Puzzle)
 
 	// Any reasonable method should be able to find a killer move.
 	puzzleMap["onePly"] = MakePuzzle(`
+type: DefiniteWin
+tags: easy
 Black to move
 B . . . . . . . . . .
  B . . . . . . . . . .
@@ -38,6 +64,8 @@ B . . . . . . . . . .
 	// MCTS can figure this out consistently in 0.2s, but not in 0.1s.
 	// Ideally this would be fast enough for a playouter to get it.
 	puzzleMap["triangleBlock"] = MakePuzzle(`
+type: DefiniteWin
+tags: default, bridges
 Black to move
 B . . . . . . . . . .
  B . . . . . . . . . .
@@ -55,6 +83,8 @@ B . . . . . . . . . .
 	// This ladder should be a win for the first player.
 	// It requires looking 21 plies deep though.
 	puzzleMap["ladder"] = MakePuzzle(`
+type: DefiniteWin
+tags: default, ladders
 Black to move
 B . . . . . . . . . .
  B . . . . . . . . . .
@@ -72,6 +102,8 @@ B . . . . . . . . . .
 	// Tree methods still cannot understand a large amount of bridges.
 	// MCTS with 0.2s can occasionally pass this but usually can't.
 	puzzleMap["manyBridges"] = MakePuzzle(`
+type: DefiniteWin
+tags: hard, bridges
 Black to move
 . . . . . . . . . . .
  . . B . . . . . . . .
@@ -101,6 +133,8 @@ Black to move
 	// winning after moving (5, 6).
 
 	puzzleMap["needle"] = MakePuzzle(`
+type: ClearMove
+tags: default, bridges
 White to move
 . . . . . . . . . W B
  . B . . . . . . W B .
@@ -118,6 +152,8 @@ White to move
 	// In the "doomedX" series, the player to move should realize that
 	// we are doomed.
 	puzzleMap["doomed1"] = MakePuzzle(`
+type: DefiniteLoss
+tags: default, doomed
 Black to move
 . . . . . . . . . . B
  . B . . . . . . . B .
@@ -133,6 +169,8 @@ Black to move
 `)
 
 	puzzleMap["doomed2"] = MakePuzzle(`
+type: DefiniteLoss
+tags: default, doomed
 Black to move
 . . . . . . . . . . B
  . B . . . . . . . B .
@@ -148,6 +186,8 @@ Black to move
 `)
 
 	puzzleMap["doomed3"] = MakePuzzle(`
+type: DefiniteLoss
+tags: default, doomed
 Black to move
 . . . . . . . . . . B
  . B . . . . . . . B .
@@ -163,6 +203,8 @@ Black to move
 `)
 
 	puzzleMap["doomed4"] = MakePuzzle(`
+type: DefiniteLoss
+tags: default, doomed
 Black to move
 . . . . . . . . . . B
  . B . . . . . . . B .
@@ -178,6 +220,8 @@ Black to move
 `)
 
 	puzzleMap["doomed6"] = MakePuzzle(`
+type: DefiniteLoss
+tags: doomed
 Black to move
 . . . . . . . . . . B
  . . . . . . . . . . B
@@ -195,6 +239,8 @@ Black to move
 	// This should be pretty straightforward - there's one obvious move
 	// to block. You won't be winning but there's still some chance.
 	puzzleMap["simpleBlock"] = MakePuzzle(`
+type: ClearMove
+tags: default
 Black to move
 . . . . . . . . . . .
  . B . . . . . . . . .
@@ -209,67 +255,66 @@ Black to move
           . . . . . . . . . . .
 `)
 
+	for name, puzzle := range puzzleMap {
+		puzzle.Name = name
+		puzzleMap[name] = puzzle
+	}
 	return puzzleMap
 }
 
 func GetPuzzle(name string) Puzzle {
-	answer, ok := PuzzleMap[name]
-	if !ok {
-		log.Fatalf("no puzzle with name: %s", name)
+	answer, err := LookupPuzzle(name)
+	if err != nil {
+		log.Fatal(err)
 	}
 	return answer
 }
 
-// The format is, the first three words are
-// "x to move" where x is Black or White
-// After that the non-white-space entries are B, ., or W
-// The move you are supposed to make is a *
-func MakePuzzle(s string) Puzzle {
-	puzzle := Puzzle{String: s, Board: new(NaiveBoard)}
-	words := strings.Fields(s)
-	if len(words) != 124 {
-		log.Fatalf("cannot make puzzle from %d words", len(words))
+// Like GetPuzzle but returns an error for an unknown puzzle.
+func LookupPuzzle(name string) (Puzzle, error) {
+	answer, ok := PuzzleMap[name]
+	if !ok {
+		return Puzzle{}, fmt.Errorf("no puzzle with name: %s", name)
 	}
+	return answer, nil
+}
 
-	switch words[0] {
-	case "Black":
-		puzzle.Board.ToMove = Black
-	case "White":
-		puzzle.Board.ToMove = White
-	default:
-		log.Fatal("bad player name: %s", words[0])
+// Makes a puzzle from text in the puzzle file format, dying if the
+// text is bad. See ParsePuzzle for the format.
+func MakePuzzle(s string) Puzzle {
+	puzzle, err := ParsePuzzle(s)
+	if err != nil {
+		log.Fatal(err)
 	}
+	return puzzle
+}
 
-	for index, spot := range AllSpots() {
-		word := words[index + 3]
-		switch word {
-		case "B":
-			puzzle.Board.Set(spot, Black)
-		case "W":
-			puzzle.Board.Set(spot, White)
-		case "*":
-			puzzle.CorrectAnswer = spot
+// Whether the move is one of the puzzle's answers.
+func (p Puzzle) IsAnswer(move NaiveSpot) bool {
+	for _, answer := range p.Answers {
+		if answer == move {
+			return true
 		}
 	}
-
-	return puzzle
+	return false
 }
 
-type puzzleScorer struct {
-	playerName string
-	right int
-	wrong int
-	total int
+// Whether a player's answer solves the puzzle. A DefiniteLoss just
+// needs pessimism, but the other types also need a correct move.
+func (p Puzzle) IsSolvedBy(move NaiveSpot, winRate float64) bool {
+	if p.Type != DefiniteLoss && !p.IsAnswer(move) {
+		return false
+	}
+	return winRate >= p.MinWinRate && winRate <= p.MaxWinRate
 }
 
-func (s *puzzleScorer) score(success bool) bool {
-	if success {
-		s.right++
-	} else {
-		s.wrong++
+func (p Puzzle) HasTag(tag string) bool {
+	for _, t := range p.Tags {
+		if t == tag {
+			return true
+		}
 	}
-	s.total++
-	return success
+	return false
 }
 
 type PuzzleType int
@@ -277,66 +322,33 @@ const (
 	DefiniteWin PuzzleType = iota
 	DefiniteLoss
 	ClearMove
-) 
-
-func (s *puzzleScorer) solve(puzzleName string, ptype PuzzleType) {
-	player := GetPlayer(s.playerName)
-	puzzle := GetPuzzle(puzzleName)
-	playerAnswer, conf := player.Play(puzzle.Board)
-
-	if ptype == DefiniteWin || ptype == ClearMove {
-		// Score the move
-		if s.score(puzzle.CorrectAnswer == playerAnswer) {
-			log.Printf("%s: move OK", puzzleName)
-		} else {
-			log.Printf("%s:%s", puzzleName, puzzle.String)
-			log.Printf("got wrong answer: %s", playerAnswer)
-			if ptype == DefiniteWin {
-				log.Printf("wrong answer means confidence doesn't matter")
-				s.score(false)
-				return
-			}
-		}
-	}
+)
 
-	if ptype == DefiniteWin {
-		// Score the confidence
-		if s.score(conf > 0.999) {
-			log.Printf("%s: conf OK", puzzleName)
-		} else {
-			log.Printf(puzzle.String)
-			log.Printf("%s: confidence is only %.2f", puzzleName, conf)
-		}
-	}
+var puzzleTypeNames = map[PuzzleType]string{
+	DefiniteWin: "DefiniteWin",
+	DefiniteLoss: "DefiniteLoss",
+	ClearMove: "ClearMove",
+}
 
-	if ptype == DefiniteLoss {
-		// Score the confidence
-		if s.score(conf < 0.001) {
-			log.Printf("%s: conf OK", puzzleName)
-		} else {
-			log.Printf(puzzle.String)
-			log.Printf("%s: confidence is unwarranted at %.2f", puzzleName, conf)
-		}
+func (t PuzzleType) String() string {
+	name, ok := puzzleTypeNames[t]
+	if !ok {
+		return fmt.Sprintf("PuzzleType(%d)", int(t))
 	}
+	return name
 }
 
-// Runs the player through a series of puzzles.
-func RunGauntlet(playerName string) {
-	s := puzzleScorer{playerName:playerName}
-
-	s.solve("doomed1", DefiniteLoss)
-	s.solve("doomed2", DefiniteLoss)
-	s.solve("doomed3", DefiniteLoss)
-	s.solve("doomed4", DefiniteLoss)
-	s.solve("triangleBlock", DefiniteWin)
-	s.solve("ladder", DefiniteWin)
-
-	// Leave out manyBridges until doomed and triangle work, because it
-	// should be even harder than those.
-	// s.solve("manyBridges", DefiniteWin)
-
-	s.solve("needle", ClearMove)
-	s.solve("simpleBlock", ClearMove)
+// Puzzle types are encoded in json by name.
+func (t PuzzleType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
 
-	log.Printf("SCORE: %d / %d", s.right, s.total)
+func (t *PuzzleType) UnmarshalText(text []byte) error {
+	for ptype, name := range puzzleTypeNames {
+		if name == string(text) {
+			*t = ptype
+			return nil
+		}
+	}
+	return fmt.Errorf("bad puzzle type: %s", text)
 }
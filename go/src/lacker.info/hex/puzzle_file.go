@@ -0,0 +1,293 @@
+package hex
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+Puzzles can be stored in .puzzle files, so the suite can grow without
+recompiling. A puzzle file looks like:
+
+# Lines starting with # are comments.
+name: needle
+type: ClearMove
+tags: default, bridges
+notes: There's one spot that makes a critical connection
+notes: for either side.
+
+White to move
+. . . . . . . . . W B
+ . B . . . . . . W B .
+...
+
+The headers come first and are all optional:
+  name: defaults to the file name without .puzzle
+  type: DefiniteWin, DefiniteLoss, or ClearMove. Defaults to ClearMove.
+  size: the board size, for puzzles smaller than BoardSize
+  answers: extra correct moves as row,col pairs, like "answers: 5,6 4,7"
+  min_win_rate, max_win_rate: the range the solver's win rate estimate
+    should be in. DefiniteWin defaults to at least 0.999 and
+    DefiniteLoss to at most 0.001.
+  tags: separated by commas or spaces
+  notes: may be repeated to make a longer note
+
+Then comes "Black to move" or "White to move", followed by the board,
+with B, W, and . for the spots. Any spot marked * is a correct move.
+*/
+
+const PuzzleExtension = ".puzzle"
+
+func parseAnswer(word string) (NaiveSpot, error) {
+	var row, col int
+	_, err := fmt.Sscanf(word, "%d,%d", &row, &col)
+	if err != nil {
+		return NaiveSpot{}, fmt.Errorf("bad answer: %s", word)
+	}
+	return MakeNaiveSpot(row, col), nil
+}
+
+// Parses a puzzle from the puzzle file format.
+func ParsePuzzle(s string) (Puzzle, error) {
+	puzzle := Puzzle{
+		String: s,
+		Type: ClearMove,
+		Size: BoardSize,
+		Answers: make([]NaiveSpot, 0),
+		Tags: make([]string, 0),
+	}
+	minSet := false
+	maxSet := false
+	notes := make([]string, 0)
+
+	// Parse headers until we get to the "x to move" line
+	lines := strings.Split(s, "\n")
+	var i int
+	for i = 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words := strings.Fields(line)
+		if len(words) == 3 && words[1] == "to" && words[2] == "move" {
+			break
+		}
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			return puzzle, fmt.Errorf("bad puzzle header: %s", line)
+		}
+		key := strings.TrimSpace(line[:colon])
+		value := strings.TrimSpace(line[colon + 1:])
+		var err error
+		switch key {
+		case "name":
+			puzzle.Name = value
+		case "type":
+			err = puzzle.Type.UnmarshalText([]byte(value))
+		case "size":
+			puzzle.Size, err = strconv.Atoi(value)
+		case "answers":
+			for _, word := range strings.Fields(value) {
+				var answer NaiveSpot
+				answer, err = parseAnswer(word)
+				if err != nil {
+					break
+				}
+				puzzle.Answers = append(puzzle.Answers, answer)
+			}
+		case "min_win_rate":
+			puzzle.MinWinRate, err = strconv.ParseFloat(value, 64)
+			minSet = true
+		case "max_win_rate":
+			puzzle.MaxWinRate, err = strconv.ParseFloat(value, 64)
+			maxSet = true
+		case "tags":
+			puzzle.Tags = append(puzzle.Tags, strings.FieldsFunc(value,
+				func(r rune) bool { return r == ',' || r == ' ' })...)
+		case "notes":
+			notes = append(notes, value)
+		default:
+			err = fmt.Errorf("unknown puzzle header: %s", key)
+		}
+		if err != nil {
+			return puzzle, err
+		}
+	}
+	puzzle.Notes = strings.Join(notes, "\n")
+
+	if puzzle.Size < 1 || puzzle.Size > BoardSize {
+		return puzzle, fmt.Errorf("bad puzzle size: %d", puzzle.Size)
+	}
+	puzzle.Board = NewSmallBoard(puzzle.Size)
+
+	// The rest is the board.
+	// The first three words are "x to move" where x is Black or White
+	// After that the non-white-space entries are B, ., W, or *
+	words := strings.Fields(strings.Join(lines[i:], "\n"))
+	if len(words) != 3 + puzzle.Size * puzzle.Size {
+		return puzzle, fmt.Errorf("cannot make a size %d puzzle from %d words",
+			puzzle.Size, len(words))
+	}
+	switch words[0] {
+	case "Black":
+		puzzle.Board.ToMove = Black
+	case "White":
+		puzzle.Board.ToMove = White
+	default:
+		return puzzle, fmt.Errorf("bad player name: %s", words[0])
+	}
+	index := 3
+	for r := 0; r < puzzle.Size; r++ {
+		for c := 0; c < puzzle.Size; c++ {
+			spot := MakeNaiveSpot(r, c)
+			switch words[index] {
+			case "B":
+				puzzle.Board.Set(spot, Black)
+			case "W":
+				puzzle.Board.Set(spot, White)
+			case "*":
+				puzzle.Answers = append(puzzle.Answers, spot)
+			case ".":
+			default:
+				return puzzle, fmt.Errorf("bad spot: %s", words[index])
+			}
+			index++
+		}
+	}
+
+	for _, answer := range puzzle.Answers {
+		if answer.IsNotASpot() || puzzle.Board.Get(answer) != Empty {
+			return puzzle, fmt.Errorf("answer %s is not an empty spot", answer)
+		}
+	}
+	if len(puzzle.Answers) > 0 {
+		puzzle.CorrectAnswer = puzzle.Answers[0]
+	} else if puzzle.Type != DefiniteLoss {
+		return puzzle, fmt.Errorf("a %s puzzle needs an answer", puzzle.Type)
+	}
+
+	if !minSet {
+		puzzle.MinWinRate = 0.0
+		if puzzle.Type == DefiniteWin {
+			puzzle.MinWinRate = 0.999
+		}
+	}
+	if !maxSet {
+		puzzle.MaxWinRate = 1.0
+		if puzzle.Type == DefiniteLoss {
+			puzzle.MaxWinRate = 0.001
+		}
+	}
+	return puzzle, nil
+}
+
+// Loads every .puzzle file in a directory, keyed by name.
+func LoadPuzzleDir(dir string) (map[string]Puzzle, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*" + PuzzleExtension))
+	if err != nil {
+		return nil, err
+	}
+	answer := make(map[string]Puzzle)
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		puzzle, err := ParsePuzzle(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		if puzzle.Name == "" {
+			puzzle.Name = strings.TrimSuffix(filepath.Base(path),
+				PuzzleExtension)
+		}
+		_, ok := answer[puzzle.Name]
+		if ok {
+			return nil, fmt.Errorf("%s: duplicate puzzle name %s", path,
+				puzzle.Name)
+		}
+		answer[puzzle.Name] = puzzle
+	}
+	return answer, nil
+}
+
+// Loads a directory of puzzles into PuzzleMap, so GetPuzzle can find
+// them. Puzzles in the directory replace built-in puzzles of the same
+// name.
+func AddPuzzleDir(dir string) error {
+	puzzles, err := LoadPuzzleDir(dir)
+	if err != nil {
+		return err
+	}
+	for name, puzzle := range puzzles {
+		PuzzleMap[name] = puzzle
+	}
+	return nil
+}
+
+// Finds puzzles by name or by tag, sorted by name. "all" finds every
+// puzzle.
+func FindPuzzles(nameOrTag string) []Puzzle {
+	puzzle, ok := PuzzleMap[nameOrTag]
+	if ok {
+		return []Puzzle{puzzle}
+	}
+	answer := make([]Puzzle, 0)
+	for _, puzzle := range PuzzleMap {
+		if nameOrTag == "all" || puzzle.HasTag(nameOrTag) {
+			answer = append(answer, puzzle)
+		}
+	}
+	sort.Slice(answer, func(i, j int) bool {
+		return answer[i].Name < answer[j].Name
+	})
+	return answer
+}
+
+// Writes a puzzle in the puzzle file format, so that ParsePuzzle can
+// read it back. Answers are marked with * on the board.
+func FormatPuzzle(p Puzzle) string {
+	lines := make([]string, 0)
+	if p.Name != "" {
+		lines = append(lines, "name: " + p.Name)
+	}
+	lines = append(lines, "type: " + p.Type.String())
+	if p.Size != BoardSize {
+		lines = append(lines, fmt.Sprintf("size: %d", p.Size))
+	}
+	lines = append(lines,
+		fmt.Sprintf("min_win_rate: %g", p.MinWinRate),
+		fmt.Sprintf("max_win_rate: %g", p.MaxWinRate))
+	if len(p.Tags) > 0 {
+		lines = append(lines, "tags: " + strings.Join(p.Tags, ", "))
+	}
+	for _, note := range strings.Split(p.Notes, "\n") {
+		if note != "" {
+			lines = append(lines, "notes: " + note)
+		}
+	}
+
+	lines = append(lines, "", p.Board.ToMove.Name() + " to move")
+	for r := 0; r < p.Size; r++ {
+		words := make([]string, 0)
+		for c := 0; c < p.Size; c++ {
+			spot := MakeNaiveSpot(r, c)
+			switch {
+			case p.IsAnswer(spot):
+				words = append(words, "*")
+			case p.Board.Get(spot) == Black:
+				words = append(words, "B")
+			case p.Board.Get(spot) == White:
+				words = append(words, "W")
+			default:
+				words = append(words, ".")
+			}
+		}
+		lines = append(lines, strings.Repeat(" ", r) + strings.Join(words, " "))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,117 @@
+package hex
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const smallPuzzle = `
+# Black connects the top to the bottom through the only gap
+name: smallGap
+type: DefiniteWin
+size: 3
+tags: small, easy
+notes: The only move that wins.
+
+Black to move
+B W .
+ B W .
+  * . .
+`
+
+func TestParsePuzzle(t *testing.T) {
+	puzzle, err := ParsePuzzle(smallPuzzle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if puzzle.Name != "smallGap" || puzzle.Type != DefiniteWin ||
+		puzzle.Size != 3 {
+		t.Fatalf("bad headers: %+v", puzzle)
+	}
+	if !puzzle.HasTag("small") || !puzzle.HasTag("easy") {
+		t.Fatalf("bad tags: %v", puzzle.Tags)
+	}
+	if puzzle.CorrectAnswer != MakeNaiveSpot(2, 0) {
+		t.Fatalf("bad answer: %s", puzzle.CorrectAnswer)
+	}
+	if puzzle.MinWinRate != 0.999 || puzzle.MaxWinRate != 1.0 {
+		t.Fatalf("bad win rates: %f %f", puzzle.MinWinRate, puzzle.MaxWinRate)
+	}
+	if !puzzle.IsSolvedBy(MakeNaiveSpot(2, 0), 1.0) {
+		t.Fatalf("the answer should solve it")
+	}
+	if puzzle.IsSolvedBy(MakeNaiveSpot(2, 0), 0.5) {
+		t.Fatalf("a DefiniteWin needs a confident solver")
+	}
+	if puzzle.IsSolvedBy(MakeNaiveSpot(2, 1), 1.0) {
+		t.Fatalf("the wrong move should not solve it")
+	}
+
+	// Outside the small board is filled in
+	if puzzle.Board.Get(MakeNaiveSpot(5, 5)) == Empty {
+		t.Fatalf("the rest of the board should be filled")
+	}
+}
+
+func TestParsePuzzleErrors(t *testing.T) {
+	bad := []string{
+		"type: Sometimes\nBlack to move\nB",
+		"size: 1\ncolor: red\nBlack to move\n*",
+		"size: 1\nGreen to move\n*",
+		"size: 2\nBlack to move\n* . . .\n.",
+		"size: 1\nBlack to move\n.",
+		"size: 1\nanswers: 3,3\nBlack to move\n.",
+	}
+	for _, s := range bad {
+		_, err := ParsePuzzle(s)
+		if err == nil {
+			t.Fatalf("expected an error parsing:\n%s", s)
+		}
+	}
+}
+
+func TestLoadPuzzleDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "puzzles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "gap.puzzle"),
+		[]byte(smallPuzzle), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "corner.puzzle"),
+		[]byte("size: 1\ntags: small\nBlack to move\n*\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	puzzles, err := LoadPuzzleDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(puzzles) != 2 {
+		t.Fatalf("expected 2 puzzles but got %d", len(puzzles))
+	}
+	if puzzles["corner"].Name != "corner" {
+		t.Fatalf("the name should default to the file name")
+	}
+	if puzzles["smallGap"].Type != DefiniteWin {
+		t.Fatalf("the name header should override the file name")
+	}
+
+	err = AddPuzzleDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer delete(PuzzleMap, "corner")
+	defer delete(PuzzleMap, "smallGap")
+	found := FindPuzzles("small")
+	if len(found) != 2 || found[0].Name != "corner" {
+		t.Fatalf("bad puzzles for the small tag: %v", found)
+	}
+}
@@ -0,0 +1,172 @@
+package hex
+
+import (
+	"fmt"
+	"log"
+)
+
+/*
+A PuzzleMiner looks through games for positions that would make good
+puzzles, using a strong search to judge each position.
+
+It looks for three kinds of position:
+  DefiniteWin: exactly one move wins, according to the search.
+  DefiniteLoss: a position where the player to move is lost, but
+    wasn't on their previous turn, so their previous move (two plies
+    back) lost the game.
+  ClearMove: the best move is much better than every other move.
+    These are where the evaluation swings sharply depending on the
+    move.
+
+The search is not exact, so these are only candidates. Someone should
+look them over before adding them to a gauntlet suite.
+*/
+
+type PuzzleMiner struct {
+	// The player type used to judge positions. It must be an Analyzer.
+	Analyzer string
+
+	// A move is winning when its win rate is at least this, and a
+	// position is lost when the best win rate is at most 1 - this.
+	Threshold float64
+
+	// How much better than every other move a ClearMove must be
+	Margin float64
+
+	// Moves with fewer playouts than this are ignored, since their win
+	// rates are mostly guesswork
+	MinPlayouts int
+}
+
+func MakePuzzleMiner(analyzer string) PuzzleMiner {
+	return PuzzleMiner{
+		Analyzer: analyzer,
+		Threshold: 0.95,
+		Margin: 0.3,
+		MinPlayouts: 100,
+	}
+}
+
+func (m *PuzzleMiner) analyzer() (Analyzer, error) {
+	player, err := LookupPlayer(m.Analyzer)
+	if err != nil {
+		return nil, err
+	}
+	analyzer, ok := player.(Analyzer)
+	if !ok {
+		return nil, fmt.Errorf("%s cannot analyze positions", m.Analyzer)
+	}
+	return analyzer, nil
+}
+
+// The moves from an analysis with enough playouts to be trusted,
+// still sorted best first.
+func (m *PuzzleMiner) trusted(a Analysis) []MoveAnalysis {
+	answer := make([]MoveAnalysis, 0)
+	for _, move := range a.Moves {
+		if move.Playouts >= m.MinPlayouts {
+			answer = append(answer, move)
+		}
+	}
+	return answer
+}
+
+// Decides whether a position makes a puzzle, given its analysis and
+// the analysis of the same player's previous turn, two plies back,
+// which may be nil. The puzzle has no name or notes yet.
+func (m *PuzzleMiner) Classify(board *NaiveBoard, a Analysis,
+	previous *Analysis) (Puzzle, bool) {
+	moves := m.trusted(a)
+	if len(moves) == 0 {
+		return Puzzle{}, false
+	}
+	puzzle := Puzzle{
+		Board: board.ToNaiveBoard(),
+		Size: BoardSize,
+		MinWinRate: 0.0,
+		MaxWinRate: 1.0,
+		Tags: []string{"mined"},
+	}
+
+	winners := make([]NaiveSpot, 0)
+	for _, move := range moves {
+		if move.WinRate >= m.Threshold {
+			winners = append(winners, move.Move)
+		}
+	}
+	if len(winners) == 1 {
+		puzzle.Type = DefiniteWin
+		puzzle.MinWinRate = 0.999
+		puzzle.Answers = winners
+		puzzle.CorrectAnswer = winners[0]
+		return puzzle, true
+	}
+	if len(winners) > 1 {
+		return Puzzle{}, false
+	}
+
+	if moves[0].WinRate <= 1.0 - m.Threshold {
+		// Only interesting if the mover's previous move lost the game
+		if previous != nil && previous.WinRate <= 1.0 - m.Threshold {
+			return Puzzle{}, false
+		}
+		puzzle.Type = DefiniteLoss
+		puzzle.MaxWinRate = 0.001
+		puzzle.Answers = []NaiveSpot{}
+		return puzzle, true
+	}
+
+	if len(moves) > 1 && moves[0].WinRate - moves[1].WinRate >= m.Margin {
+		puzzle.Type = ClearMove
+		puzzle.Answers = []NaiveSpot{moves[0].Move}
+		puzzle.CorrectAnswer = moves[0].Move
+		return puzzle, true
+	}
+	return Puzzle{}, false
+}
+
+// Analyzes every position in a game and returns the candidate puzzles.
+// Each puzzle is named with the prefix and the ply it came from.
+func (m *PuzzleMiner) MineGame(record GameRecord, prefix string) (
+	[]Puzzle, error) {
+	analyzer, err := m.analyzer()
+	if err != nil {
+		return nil, err
+	}
+	answer := make([]Puzzle, 0)
+
+	// The analyses of the last two positions, the older one first
+	var previous [2]*Analysis
+	for ply := 0; ply < len(record.Moves); ply++ {
+		board, err := record.BoardAtPly(ply)
+		if err != nil {
+			return nil, err
+		}
+		a := analyzer.Analyze(board)
+		puzzle, ok := m.Classify(board, a, previous[0])
+		previous[0], previous[1] = previous[1], &a
+		if !ok {
+			continue
+		}
+
+		puzzle.Name = fmt.Sprintf("%s%d", prefix, ply)
+		puzzle.Notes = fmt.Sprintf(
+			"Mined from ply %d of %s vs %s, where %s played %s.\n" +
+				"%s estimated the win rate at %.3f with %s.",
+			ply, record.Black, record.White, record.PlayerForPly(ply),
+			record.Moves[ply], m.Analyzer, a.WinRate, a.Move)
+		answer = append(answer, puzzle)
+		log.Printf("found a %s puzzle at ply %d", puzzle.Type, ply)
+	}
+	return answer, nil
+}
+
+// Plays a game of the player against itself and mines it.
+func (m *PuzzleMiner) MineSelfPlay(player string, prefix string) (
+	[]Puzzle, error) {
+	record, err := PlayGameBetween(player, player, nil)
+	if err != nil {
+		return nil, err
+	}
+	return m.MineGame(record, prefix)
+}
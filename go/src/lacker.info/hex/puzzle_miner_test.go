@@ -0,0 +1,83 @@
+package hex
+
+import (
+	"testing"
+)
+
+func analysisOf(winRates ...float64) Analysis {
+	a := Analysis{Moves: make([]MoveAnalysis, 0)}
+	for i, winRate := range winRates {
+		a.Moves = append(a.Moves, MoveAnalysis{
+			Move: MakeNaiveSpot(0, i),
+			Playouts: 1000,
+			WinRate: winRate,
+		})
+	}
+	a.Move = a.Moves[0].Move
+	a.WinRate = a.Moves[0].WinRate
+	return a
+}
+
+func TestClassify(t *testing.T) {
+	miner := MakePuzzleMiner("mcts1")
+	board := NewNaiveBoard()
+
+	puzzle, ok := miner.Classify(board, analysisOf(0.99, 0.6, 0.5), nil)
+	if !ok || puzzle.Type != DefiniteWin ||
+		puzzle.CorrectAnswer != MakeNaiveSpot(0, 0) {
+		t.Fatalf("expected a DefiniteWin but got %+v", puzzle)
+	}
+
+	_, ok = miner.Classify(board, analysisOf(0.99, 0.98, 0.5), nil)
+	if ok {
+		t.Fatalf("two winning moves should not make a puzzle")
+	}
+
+	puzzle, ok = miner.Classify(board, analysisOf(0.02, 0.01), nil)
+	if !ok || puzzle.Type != DefiniteLoss {
+		t.Fatalf("expected a DefiniteLoss but got %+v", puzzle)
+	}
+	// The mover's previous move, two plies back, lost the game
+	previous := analysisOf(0.6, 0.5)
+	puzzle, ok = miner.Classify(board, analysisOf(0.02, 0.01), &previous)
+	if !ok || puzzle.Type != DefiniteLoss {
+		t.Fatalf("expected a DefiniteLoss but got %+v", puzzle)
+	}
+	previous = analysisOf(0.03, 0.01)
+	_, ok = miner.Classify(board, analysisOf(0.02, 0.01), &previous)
+	if ok {
+		t.Fatalf("the mover was already lost on their previous turn")
+	}
+
+	puzzle, ok = miner.Classify(board, analysisOf(0.8, 0.4, 0.3), nil)
+	if !ok || puzzle.Type != ClearMove {
+		t.Fatalf("expected a ClearMove but got %+v", puzzle)
+	}
+
+	_, ok = miner.Classify(board, analysisOf(0.6, 0.5, 0.4), nil)
+	if ok {
+		t.Fatalf("a close position should not make a puzzle")
+	}
+}
+
+func TestFormatPuzzle(t *testing.T) {
+	for name, puzzle := range PuzzleMap {
+		parsed, err := ParsePuzzle(FormatPuzzle(puzzle))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if parsed.Name != name || parsed.Type != puzzle.Type ||
+			parsed.Board.ToMove != puzzle.Board.ToMove ||
+			len(parsed.Answers) != len(puzzle.Answers) ||
+			parsed.MinWinRate != puzzle.MinWinRate ||
+			parsed.MaxWinRate != puzzle.MaxWinRate {
+			t.Fatalf("%s did not survive formatting:\n%s", name,
+				FormatPuzzle(puzzle))
+		}
+		for _, spot := range AllSpots() {
+			if parsed.Board.Get(spot) != puzzle.Board.Get(spot) {
+				t.Fatalf("%s has a different board after formatting", name)
+			}
+		}
+	}
+}
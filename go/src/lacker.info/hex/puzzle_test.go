@@ -13,7 +13,7 @@ type checker struct {
 
 func (c checker) expectPass(puzzle Puzzle) {
 	playerAnswer, _ := c.Player.Play(puzzle.Board)
-	if puzzle.CorrectAnswer != playerAnswer {
+	if !puzzle.IsAnswer(playerAnswer) {
 		c.Tester.Errorf("With puzzle: %s", puzzle.String)
 		c.Tester.Errorf("%s gave incorrect answer: %s", c.Name, playerAnswer)
 	}
@@ -21,7 +21,7 @@ func (c checker) expectPass(puzzle Puzzle) {
 
 func (c checker) expectFail(puzzle Puzzle) {
 	playerAnswer, _ := c.Player.Play(puzzle.Board)
-	if puzzle.CorrectAnswer == playerAnswer {
+	if puzzle.IsAnswer(playerAnswer) {
 		c.Tester.Errorf("With puzzle: %s", puzzle.String)
 		c.Tester.Errorf("%s was supposed to fail but passed.", c.Name)
 	}
@@ -35,12 +35,12 @@ func TestPuzzles(t *testing.T) {
 	sr := checker{
 		Tester: t,
 		Name: "SR",
-		Player: ShallowRave{Seconds:0.1, Quiet:true},
+		Player: ShallowRave{SearchLimits: SearchLimits{Seconds: 0.1}, Quiet:true},
 	}
 	mcts := checker{
 		Tester: t,
 		Name: "MCTS",
-		Player: MonteCarloTreeSearch{Seconds:0.2, Quiet:true, V:0},
+		Player: &MonteCarloTreeSearch{SearchLimits: SearchLimits{Seconds: 0.2}, Quiet:true, V:0},
 	}
 
 	onePly := GetPuzzle("onePly")
@@ -140,6 +140,9 @@ type QNet struct {
 	// The fraction of the time we intentionally go off-policy in order
 	// to handicap this player.
 	handicap float64
+
+	// Where exploring gets its randomness
+	rand *rand.Rand
 }
 
 // Creates a new qnet that has no values on any features and thus just
@@ -150,6 +153,7 @@ func NewQNet(board *TopoBoard, color Color) *QNet {
 		color: color,
 		emptySpots: board.PossibleTopoSpotMoves(),
 		bias: QNeuron{},
+		rand: globalRand,
 	}
 
 	for feature := MinFeature; feature <= MaxFeature; feature++ {
@@ -232,11 +236,11 @@ func (qnet *QNet) IdealAction(board *TopoBoard, maybeExplore bool) QAction {
 	if maybeExplore {
 		// A Q of 3 corresponds to a win chance of around 95%.
 		// If we are still 95% likely to win then it seems okay to explore.
-		if explorationQ > 3.0 && rand.Float64() > 0.5 {
+		if explorationQ > 3.0 && qnet.rand.Float64() > 0.5 {
 			explore = true
 		}
 		// Sometimes we explore extra in order to handicap a player
-		if rand.Float64() < qnet.handicap {
+		if qnet.rand.Float64() < qnet.handicap {
 			explore = true
 		}
 	}
@@ -257,7 +261,7 @@ func (qnet *QNet) IdealAction(board *TopoBoard, maybeExplore bool) QAction {
 }
 
 func (qnet *QNet) Reset() {
-	ShuffleTopoSpots(qnet.emptySpots)
+	ShuffleTopoSpotsWith(qnet.rand, qnet.emptySpots)
 
 	qnet.baseV = qnet.bias.weight
 
@@ -17,9 +17,13 @@ import (
 // of MetaFarmer.
 
 type QTrainer struct {
-	Seconds float64
+	SearchLimits
+	SeededRand
 	Quiet bool
 
+	// Gets told about progress during training, if set
+	Observer SearchObserver
+
 	// The nets we are training, one per player
 	whiteNet *QNet
 	blackNet *QNet
@@ -45,9 +49,29 @@ type QTrainer struct {
 	handicap Color
 }
 
+func (trainer *QTrainer) SetObserver(observer SearchObserver) {
+	trainer.Observer = observer
+}
+
+func init() {
+	RegisterPlayerType(PlayerType{
+		Name: "qt",
+		Help: "trains a q net on playouts from the position",
+		Options: []PlayerOption{
+			{"seconds", "5", "how long to think per move"},
+			{"quiet", "false", "whether to skip logging"},
+		},
+		Make: func(o *PlayerOptions) Player {
+			return &QTrainer{SearchLimits: o.Limits(), Quiet: o.Bool("quiet")}
+		},
+	})
+}
+
 func (trainer *QTrainer) init(b *TopoBoard) {
 	trainer.whiteNet = NewQNet(b, White)
 	trainer.blackNet = NewQNet(b, Black)
+	trainer.whiteNet.rand = trainer.Rand()
+	trainer.blackNet.rand = trainer.Rand()
 	trainer.playouts = []*QPlayout{}
 }
 
@@ -192,9 +216,21 @@ func (trainer *QTrainer) Play(b Board) (NaiveSpot, float64) {
 
 	if !Debug {
 		start := time.Now()
-		for SecondsSince(start) < trainer.Seconds {
+		clock := newProgressClock()
+		before := trainer.games
+		for !trainer.Reached(start, trainer.games - before, 0) {
 			trainer.PlayBatch(DefaultBatchSize, false)
 			trainer.LearnFromBatch(false)
+			if clock.due(trainer.Observer) {
+				move, winRate := trainer.BestMoveAndWinRate()
+				trainer.Observer.ObserveSearch(SearchProgress{
+					BestMove: move.NaiveSpot(),
+					WinRate: winRate,
+					Playouts: trainer.games,
+					Seconds: clock.seconds(),
+					PrincipalVariation: []NaiveSpot{move.NaiveSpot()},
+				})
+			}
 		}
 	} else {
 		rand.Seed(1)
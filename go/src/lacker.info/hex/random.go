@@ -1,17 +1,24 @@
 package hex
 
-import (
-	"math/rand"
-)
-
 /*
 The random player plays a random legal move.
 */
 
 type Random struct {
+	SeededRand
+}
+
+func init() {
+	RegisterPlayerType(PlayerType{
+		Name: "random",
+		Help: "moves randomly",
+		Make: func(o *PlayerOptions) Player {
+			return &Random{}
+		},
+	})
 }
 
 func (r Random) Play(b Board) (NaiveSpot, float64) {
 	moves := b.PossibleMoves()
-	return moves[rand.Intn(len(moves))], 0.5
+	return moves[r.Rand().Intn(len(moves))], 0.5
 }
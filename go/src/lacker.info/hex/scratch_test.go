@@ -0,0 +1,30 @@
+package hex
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScratch(t *testing.T) {
+	s := Solver{KeepTable: true}
+	start := time.Now()
+	r := s.Solve(NewSmallBoard(5))
+	proven := 0
+	for _, e := range s.table {
+		if e.phi == 0 || e.delta == 0 {
+			proven++
+		}
+	}
+	t.Logf("empty 5x5: %s table %d proven %d in %.1fs", r, len(s.table), proven, SecondsSince(start))
+	o, _ := NewOracle(5)
+	start = time.Now()
+	n := 0
+	b := NewSmallBoard(5)
+	for _, m1 := range b.PossibleMoves() {
+		c := b.ToNaiveBoard()
+		c.MakeMove(m1)
+		o.WinningMoves(c)
+		n++
+	}
+	t.Logf("%d one-stone positions' winning moves: %.1fs cache %d table %d", n, SecondsSince(start), len(o.cache), len(o.solver.table))
+}
@@ -0,0 +1,125 @@
+package hex
+
+import (
+	"math/rand"
+	"time"
+)
+
+/*
+Players that search are told how much to search with SearchLimits, and
+draw their randomness from their own source with SeededRand. Both get
+embedded in the players.
+
+A time limit depends on how fast the machine is and what else it's
+doing, so for results that can be reproduced, limit playouts or nodes
+instead and give a seed, like:
+
+mcts:seconds=0,playouts=2000,seed=7
+
+The same spec with the same seed then always makes the same moves,
+with any number of threads. The exception is ss, whose threads share
+one ranking, so it only repeats itself on one thread.
+*/
+
+// How much a player may search for each move. A zero field doesn't
+// limit anything, and the search stops at whichever limit it hits
+// first. With no limits at all, a search does as little as it can.
+type SearchLimits struct {
+	Seconds float64
+
+	// Random games played to the end
+	Playouts int
+
+	// New positions stored in a search tree. Players without a tree
+	// ignore this.
+	Nodes int
+}
+
+func (l *SearchLimits) SetSeconds(seconds float64) {
+	l.Seconds = seconds
+}
+
+func (l *SearchLimits) Limits() SearchLimits {
+	return *l
+}
+
+func (l *SearchLimits) SetLimits(limits SearchLimits) {
+	*l = limits
+}
+
+// Whether a search that started at start, and has done this many
+// playouts and added this many nodes, should stop.
+func (l SearchLimits) Reached(start time.Time, playouts int, nodes int) bool {
+	if l.Playouts > 0 && playouts >= l.Playouts {
+		return true
+	}
+	if l.Nodes > 0 && nodes >= l.Nodes {
+		return true
+	}
+	if l.Seconds > 0 {
+		return SecondsSince(start) >= l.Seconds
+	}
+	return l.Playouts <= 0 && l.Nodes <= 0
+}
+
+// The limits for one of several threads that search separately, so
+// that between them they do the playouts and nodes of these limits.
+// The split doesn't depend on timing, so a seeded search stays the
+// same.
+func (l SearchLimits) Split(threads int, thread int) SearchLimits {
+	share := func(total int) int {
+		if total <= 0 {
+			return total
+		}
+		answer := total / threads
+		if thread < total % threads {
+			answer++
+		}
+		// A thread with nothing to do would be unlimited
+		return Intmax(answer, 1)
+	}
+	return SearchLimits{
+		Seconds: l.Seconds,
+		Playouts: share(l.Playouts),
+		Nodes: share(l.Nodes),
+	}
+}
+
+// A LimitedPlayer's search limits can be changed between moves.
+type LimitedPlayer interface {
+	TimedPlayer
+	Limits() SearchLimits
+	SetLimits(limits SearchLimits)
+}
+
+// A player's own source of randomness. Until it's seeded it uses the
+// global source, which differs from run to run.
+// It isn't safe to use from several goroutines, so each goroutine
+// should get its own with NewRand.
+type SeededRand struct {
+	r *rand.Rand
+}
+
+func (s *SeededRand) SetSeed(seed int64) {
+	s.r = rand.New(rand.NewSource(seed))
+}
+
+func (s *SeededRand) Rand() *rand.Rand {
+	if s.r == nil {
+		return globalRand
+	}
+	return s.r
+}
+
+// A new source of randomness, seeded from this one, so that seeding
+// this one decides it too.
+func (s *SeededRand) NewRand() *rand.Rand {
+	return rand.New(rand.NewSource(s.Rand().Int63()))
+}
+
+// A SeededPlayer makes the same moves every time once it's seeded,
+// as long as its search is limited by something other than time.
+type SeededPlayer interface {
+	Player
+	SetSeed(seed int64)
+}
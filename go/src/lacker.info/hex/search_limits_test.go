@@ -0,0 +1,80 @@
+package hex
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSearchLimits(t *testing.T) {
+	start := time.Now()
+	if !(SearchLimits{}).Reached(start, 0, 0) {
+		t.Fatalf("no limits should mean no searching")
+	}
+	limits := SearchLimits{Playouts: 10, Nodes: 5}
+	if limits.Reached(start, 9, 4) || !limits.Reached(start, 10, 0) ||
+		!limits.Reached(start, 0, 5) {
+		t.Fatalf("bad limits")
+	}
+	if (SearchLimits{Seconds: 100}).Reached(start, 1000, 1000) {
+		t.Fatalf("only time should limit this")
+	}
+
+	playouts := 0
+	for i := 0; i < 3; i++ {
+		playouts += limits.Split(3, i).Playouts
+	}
+	if playouts != 10 || limits.Split(20, 19).Nodes != 1 ||
+		(SearchLimits{}).Split(3, 1).Playouts != 0 {
+		t.Fatalf("bad split")
+	}
+}
+
+func TestPlayoutLimits(t *testing.T) {
+	for _, threads := range []int{1, 3} {
+		mcts := MakeMCTS(0)
+		mcts.Quiet = true
+		mcts.Threads = threads
+		mcts.Playouts = 100
+		root := mcts.Search(NewNaiveBoard())
+		if root.NumPlayouts() != 100 {
+			t.Fatalf("expected 100 playouts on %d threads but got %d", threads,
+				root.NumPlayouts())
+		}
+	}
+}
+
+func TestSeededPlayers(t *testing.T) {
+	board := PuzzleMap["manyBridges"].Board
+	small := NewSmallBoard(4)
+	small.MakeMove(MakeNaiveSpot(1, 2))
+	specs := []string{
+		"random:seed=3",
+		"mcts:seconds=0,playouts=300,quiet=true,seed=3",
+		"mcts:seconds=0,playouts=300,threads=3,quiet=true,seed=3",
+		"mcts:seconds=0,nodes=200,tt=true,topo=true,quiet=true,seed=3",
+		"sr:seconds=0,playouts=200,threads=2,quiet=true,seed=3",
+		"ss:seconds=0,playouts=100,quiet=true",
+		"qt:seconds=0,playouts=20,quiet=true,seed=3",
+		"oracle:seed=3",
+	}
+	for _, spec := range specs {
+		for _, b := range []Board{board, small} {
+			move, winRate := GetPlayer(spec).Play(b)
+			for i := 0; i < 2; i++ {
+				again, againWinRate := GetPlayer(spec).Play(b)
+				if again != move || againWinRate != winRate {
+					t.Fatalf("%s played %s at %.3f, then %s at %.3f", spec, move,
+						winRate, again, againWinRate)
+				}
+			}
+		}
+	}
+
+	// These don't use randomness at all
+	for _, spec := range []string{"mf:seed=3", "ss:seed=3"} {
+		_, err := LookupPlayer(spec)
+		if err == nil {
+			t.Fatalf("%s has nothing to seed", spec)
+		}
+	}
+}
@@ -2,6 +2,9 @@ package hex
 
 import (
 	"log"
+	"math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -9,6 +12,10 @@ import (
 The shallow rave algorithm is that you do playouts from the given
 position, and the spot with (roughly) the best win/loss record when
 the player to move moves there is the best spot.
+
+With more than one thread, each goroutine does playouts into its own
+records, and the records are added up at the end. That's the same as
+doing all the playouts on one goroutine, just faster.
 */
 
 type WinLossRecord struct {
@@ -22,71 +29,164 @@ func (r *WinLossRecord) Score() float64 {
 }
 
 type ShallowRave struct {
-	Seconds float64
+	SearchLimits
+	SeededRand
 	Quiet bool
+
+	// How many goroutines do playouts. Zero means one.
+	Threads int
+
+	// Gets told about progress during the search, if set
+	Observer SearchObserver
 }
 
-func (s ShallowRave) Play(b Board) (NaiveSpot, float64) {
-	start := time.Now()
+func (s *ShallowRave) SetObserver(observer SearchObserver) {
+	s.Observer = observer
+}
 
+func init() {
+	RegisterPlayerType(PlayerType{
+		Name: "sr",
+		Help: "shallow rave. plays out randomly and picks the best record",
+		Options: []PlayerOption{
+			{"seconds", "1", "how long to think per move"},
+			{"quiet", "false", "whether to skip logging"},
+			{"threads", "1", "how many goroutines to do playouts with"},
+		},
+		Make: func(o *PlayerOptions) Player {
+			return &ShallowRave{
+				SearchLimits: o.Limits(),
+				Quiet: o.Bool("quiet"),
+				Threads: o.Int("threads"),
+			}
+		},
+	})
+}
+
+// Finds the move with the best record so far. Ties go to the first
+// move in spot order.
+func bestRecord(records map[NaiveSpot]*WinLossRecord) (NaiveSpot, float64) {
+	bestScore := -1.0
+	bestMove := MakeNaiveSpot(-1, -1)
+	for _, move := range AllSpots() {
+		record, ok := records[move]
+		if ok && record.Score() > bestScore {
+			bestScore = record.Score()
+			bestMove = move
+		}
+	}
+	return bestMove, bestScore
+}
+
+// An empty record for every move from b.
+func newRecords(b Board) map[NaiveSpot]*WinLossRecord {
 	records := make(map[NaiveSpot]*WinLossRecord)
-	moves := b.PossibleMoves()
-	for _, move := range moves {
+	for _, move := range b.PossibleMoves() {
 		records[move] = new(WinLossRecord)
 	}
+	return records
+}
 
-	playouts := 0
-	for SecondsSince(start) < s.Seconds {
-		playouts++
+// Adds the records in other to records.
+func addRecords(records map[NaiveSpot]*WinLossRecord,
+	other map[NaiveSpot]*WinLossRecord) {
+	for move, record := range other {
+		records[move].Wins += record.Wins
+		records[move].Losses += record.Losses
+	}
+}
 
-		// To playout, first shuffle all possible moves
-		// This could be based on Board.Playout - that would probably be a
-		// better design.
-		moves := b.PossibleMoves()
-		if len(moves) == 0 {
-			log.Fatal("no possible moves")
-		}
-		ShuffleSpots(moves)
-
-		// Then play moves in that order on a copy of the board.
-		// Track the moves that "we" played, i.e. the player to move on b
-		playout := b.ToNaiveBoard()
-		ourMoves := make([]NaiveSpot, 0)
-		for _, move := range moves {
-			if playout.ToMove == b.GetToMove() {
-				ourMoves = append(ourMoves, move)
-			}
-			playout.MakeMoveWithNaiveSpot(move)
+// Does one random playout from b, and counts it for every move the
+// player to move made in it.
+func shallowRavePlayout(b Board, records map[NaiveSpot]*WinLossRecord,
+	r *rand.Rand) {
+	// To playout, first shuffle all possible moves
+	// This could be based on Board.Playout - that would probably be a
+	// better design.
+	moves := b.PossibleMoves()
+	if len(moves) == 0 {
+		log.Fatal("no possible moves")
+	}
+	ShuffleSpotsWith(r, moves)
+
+	// Then play moves in that order on a copy of the board.
+	// Track the moves that "we" played, i.e. the player to move on b
+	playout := b.ToNaiveBoard()
+	ourMoves := make([]NaiveSpot, 0)
+	for _, move := range moves {
+		if playout.ToMove == b.GetToMove() {
+			ourMoves = append(ourMoves, move)
 		}
+		playout.MakeMoveWithNaiveSpot(move)
+	}
 
-		winner := playout.Winner()
-		if winner == Empty {
-			playout.Eprint()
-			log.Fatal("there was no winner after a full playout")
+	winner := playout.Winner()
+	if winner == Empty {
+		playout.Eprint()
+		log.Fatal("there was no winner after a full playout")
+	}
+	if winner == b.GetToMove() {
+		// We won.
+		for _, move := range ourMoves {
+			records[move].Wins++
 		}
-		if winner == b.GetToMove() {
-			// We won.
-			for _, move := range ourMoves {
-				records[move].Wins++
-			}
-		} else {
-			// We lost.
-			for _, move := range ourMoves {
-				records[move].Losses++
+	} else {
+		// We lost.
+		for _, move := range ourMoves {
+			records[move].Losses++
+		}
+	}
+}
+
+func (s ShallowRave) Play(b Board) (NaiveSpot, float64) {
+	start := time.Now()
+
+	// Each thread gets its own records and randomness
+	threads := Intmax(s.Threads, 1)
+	tables := make([]map[NaiveSpot]*WinLossRecord, threads)
+	for i := range tables {
+		tables[i] = newRecords(b)
+	}
+	var playouts int64
+	var wg sync.WaitGroup
+	for i, records := range tables[1:] {
+		wg.Add(1)
+		go func(records map[NaiveSpot]*WinLossRecord, r *rand.Rand,
+			limits SearchLimits) {
+			defer wg.Done()
+			for done := 0; !limits.Reached(start, done, 0); done++ {
+				shallowRavePlayout(b, records, r)
+				atomic.AddInt64(&playouts, 1)
 			}
+		}(records, s.NewRand(), s.Split(threads, i + 1))
+	}
+
+	// The first thread reports progress, from its own records
+	records := tables[0]
+	limits := s.Split(threads, 0)
+	clock := newProgressClock()
+	for done := 0; !limits.Reached(start, done, 0); done++ {
+		if clock.due(s.Observer) {
+			move, score := bestRecord(records)
+			s.Observer.ObserveSearch(SearchProgress{
+				BestMove: move,
+				WinRate: score,
+				Playouts: int(atomic.LoadInt64(&playouts)),
+				Seconds: clock.seconds(),
+				PrincipalVariation: []NaiveSpot{move},
+			})
 		}
+		shallowRavePlayout(b, records, s.Rand())
+		atomic.AddInt64(&playouts, 1)
+	}
+	wg.Wait()
+	for _, other := range tables[1:] {
+		addRecords(records, other)
 	}
 
 	// We have finished all the playouts. Now we just need to choose
 	// the best-scoring move.
-	bestScore := -1.0
-	bestMove := MakeNaiveSpot(-1, -1)
-	for move, record := range records {
-		if record.Score() > bestScore {
-			bestScore = record.Score()
-			bestMove = move
-		}
-	}
+	bestMove, bestScore := bestRecord(records)
 	if bestMove.Row() == -1 {
 		log.Fatal("there was no nonnegative score")
 	}
@@ -96,4 +196,3 @@ func (s ShallowRave) Play(b Board) (NaiveSpot, float64) {
 	}
 	return bestMove, bestScore
 }
-
@@ -0,0 +1,57 @@
+package hex
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestShallowRaveRecordsAddUp(t *testing.T) {
+	b := PuzzleMap["onePly"].Board
+
+	// Two threads' worth of playouts, added up
+	first := newRecords(b)
+	second := newRecords(b)
+	for i := 0; i < 50; i++ {
+		shallowRavePlayout(b, first, rand.New(rand.NewSource(int64(i))))
+		shallowRavePlayout(b, second, rand.New(rand.NewSource(int64(i + 50))))
+	}
+	addRecords(first, second)
+
+	// The same playouts on one thread
+	single := newRecords(b)
+	for i := 0; i < 100; i++ {
+		shallowRavePlayout(b, single, rand.New(rand.NewSource(int64(i))))
+	}
+
+	for move, record := range single {
+		if *record != *first[move] {
+			t.Fatalf("%s has record %v on one thread but %v added up", move,
+				*record, *first[move])
+		}
+	}
+}
+
+func TestParallelShallowRave(t *testing.T) {
+	sr := ShallowRave{SearchLimits: SearchLimits{Seconds: 0.2}, Quiet: true, Threads: 4}
+	move, _ := sr.Play(PuzzleMap["onePly"].Board)
+	if move != MakeNaiveSpot(10, 0) {
+		t.Fatalf("expected the winning move but got %s", move)
+	}
+}
+
+func TestParallelSpotSorter(t *testing.T) {
+	b := NewSmallBoard(4)
+	s := &SpotSorter{SearchLimits: SearchLimits{Playouts: 205}, Threads: 3}
+	s.Init(b)
+	s.run(b, time.Now())
+	if s.wins + s.losses != 205 || len(s.ranked) != 16 {
+		t.Fatalf("expected 205 playouts over 16 spots but got %d over %d",
+			s.wins + s.losses, len(s.ranked))
+	}
+	for i := 1; i < len(s.ranked); i++ {
+		if s.ranked[i - 1].Score < s.ranked[i].Score {
+			t.Fatalf("the spots aren't sorted by score")
+		}
+	}
+}
@@ -0,0 +1,493 @@
+package hex
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+The Solver finds the exact game-theoretic value of a position, using
+depth-first proof-number search (df-pn) with a transposition table.
+
+Every node gets a proof number phi, the cost to prove that the player
+to move wins, and a disproof number delta, the cost to prove that
+they lose. A node wins if any child loses, so
+  phi = min over children of the child's delta
+  delta = sum over children of the child's phi
+and the search always descends into the child that looks cheapest to
+refute, until one of the thresholds is exceeded.
+
+Positions are cut off without searching them when:
+  the player to move can win immediately,
+  either player is connected with bridges whose carriers don't overlap,
+    which is a win for them no matter who moves,
+  the opponent has two places to win immediately.
+When the opponent has exactly one place to win immediately, that's
+the only move considered.
+
+Small boards and the puzzles near the end of a game solve in well
+under a second. Open positions on the full board are out of reach:
+the needle and simpleBlock puzzles are still unproven after hundreds
+of thousands of nodes, since most of the board is empty and few
+bridges cut the search down. Give the solver a budget for positions
+like that, and it returns an unproven result when it runs out.
+*/
+
+// The built-in puzzles the solver can't prove in any reasonable budget.
+var UnsolvablePuzzles = []string{"needle", "simpleBlock"}
+
+// Proof numbers at or above this are infinite.
+const proofInfinity int64 = 1 << 40
+
+type proofEntry struct {
+	phi int64
+	delta int64
+
+	// Whether the value was found without looking at children
+	static bool
+
+	// For a static win, a move that keeps it
+	move TopoSpot
+}
+
+// A bridge links two spots of the same color through two empty spots.
+// The edge template, a spot on the second row with two empty spots
+// below it, is a bridge to the side.
+type bridge struct {
+	other TopoSpot
+	carrier [2]TopoSpot
+}
+
+var topoNeighbors, topoBridges = makeTopoTables()
+
+func makeTopoTables() ([NumTopoSpots][]TopoSpot, [NumTopoSpots][]bridge) {
+	var neighbors [NumTopoSpots][]TopoSpot
+	var bridges [NumTopoSpots][]bridge
+	inBounds := func(r int, c int) bool {
+		return r >= 0 && r < BoardSize && c >= 0 && c < BoardSize
+	}
+	link := func(s TopoSpot, other TopoSpot) {
+		neighbors[s] = append(neighbors[s], other)
+		if other.isSpecialSpot() {
+			neighbors[other] = append(neighbors[other], s)
+		}
+	}
+	addBridge := func(s TopoSpot, other TopoSpot, c1 TopoSpot, c2 TopoSpot) {
+		bridges[s] = append(bridges[s], bridge{other, [2]TopoSpot{c1, c2}})
+		if other.isSpecialSpot() {
+			bridges[other] = append(bridges[other],
+				bridge{s, [2]TopoSpot{c1, c2}})
+		}
+	}
+
+	// The neighbors in clockwise order, so consecutive ones are
+	// adjacent to each other
+	dr := []int{-1, -1, 0, 1, 1, 0}
+	dc := []int{0, 1, 1, 0, -1, -1}
+	for r := 0; r < BoardSize; r++ {
+		for c := 0; c < BoardSize; c++ {
+			s := MakeTopoSpot(r, c)
+			for i := range dr {
+				if inBounds(r + dr[i], c + dc[i]) {
+					link(s, MakeTopoSpot(r + dr[i], c + dc[i]))
+				}
+				j := (i + 1) % len(dr)
+				br, bc := r + dr[i] + dr[j], c + dc[i] + dc[j]
+				if inBounds(br, bc) && inBounds(r + dr[i], c + dc[i]) &&
+					inBounds(r + dr[j], c + dc[j]) {
+					addBridge(s, MakeTopoSpot(br, bc),
+						MakeTopoSpot(r + dr[i], c + dc[i]),
+						MakeTopoSpot(r + dr[j], c + dc[j]))
+				}
+			}
+
+			// The sides
+			if r == 0 {
+				link(s, TopSide)
+			}
+			if r == BoardSize - 1 {
+				link(s, BottomSide)
+			}
+			if c == 0 {
+				link(s, LeftSide)
+			}
+			if c == BoardSize - 1 {
+				link(s, RightSide)
+			}
+
+			// The edge templates
+			if r == 1 && c + 1 < BoardSize {
+				addBridge(s, TopSide, MakeTopoSpot(0, c), MakeTopoSpot(0, c + 1))
+			}
+			if r == BoardSize - 2 && c >= 1 {
+				addBridge(s, BottomSide, MakeTopoSpot(r + 1, c),
+					MakeTopoSpot(r + 1, c - 1))
+			}
+			if c == 1 && r + 1 < BoardSize {
+				addBridge(s, LeftSide, MakeTopoSpot(r, 0),
+					MakeTopoSpot(r + 1, 0))
+			}
+			if c == BoardSize - 2 && r >= 1 {
+				addBridge(s, RightSide, MakeTopoSpot(r, c + 1),
+					MakeTopoSpot(r - 1, c + 1))
+			}
+		}
+	}
+	return neighbors, bridges
+}
+
+// The two sides that a color is trying to connect.
+func sidesFor(color Color) (TopoSpot, TopoSpot) {
+	if color == Black {
+		return TopSide, BottomSide
+	}
+	return LeftSide, RightSide
+}
+
+// Whether the color would win by playing on the empty spot s.
+func (b *TopoBoard) wouldWin(s TopoSpot, color Color) bool {
+	side1, side2 := sidesFor(color)
+	touches1 := false
+	touches2 := false
+	for _, n := range topoNeighbors[s] {
+		if b.Board[n] != color {
+			continue
+		}
+		group := b.GroupId[n]
+		touches1 = touches1 || group == b.GroupId[side1]
+		touches2 = touches2 || group == b.GroupId[side2]
+	}
+	return touches1 && touches2
+}
+
+// The empty spots where the color would win immediately.
+func (b *TopoBoard) winningSpots(color Color) []TopoSpot {
+	answer := make([]TopoSpot, 0)
+	for spot := TopLeftCorner; spot <= BottomRightCorner; spot++ {
+		if b.Board[spot] == Empty && b.wouldWin(spot, color) {
+			answer = append(answer, spot)
+		}
+	}
+	return answer
+}
+
+// Looks for a chain of groups of the color, linked by bridges, that
+// connects its sides, where no two bridges share an empty spot. The
+// color wins from such a position no matter who is to move, since any
+// intrusion into a bridge can be answered by the other spot.
+// Returns the bridge carriers, or false if no such chain was found.
+// This is conservative: it only tries the shortest chain.
+func (b *TopoBoard) bridgeConnection(color Color) ([][2]TopoSpot, bool) {
+	side1, side2 := sidesFor(color)
+	start := b.GroupId[side1]
+	goal := b.GroupId[side2]
+	if start == goal {
+		return nil, true
+	}
+
+	type link struct {
+		from TopoSpot
+		carrier [2]TopoSpot
+	}
+	parents := make(map[TopoSpot]link)
+	parents[start] = link{from: NotASpot}
+	queue := []TopoSpot{start}
+	for len(queue) > 0 {
+		group := queue[0]
+		queue = queue[1:]
+		for _, s := range b.GroupSpots[group] {
+			for _, br := range topoBridges[s] {
+				if b.Board[br.other] != color ||
+					b.Board[br.carrier[0]] != Empty ||
+					b.Board[br.carrier[1]] != Empty {
+					continue
+				}
+				next := b.GroupId[br.other]
+				_, seen := parents[next]
+				if seen {
+					continue
+				}
+				parents[next] = link{group, br.carrier}
+				queue = append(queue, next)
+			}
+		}
+		_, done := parents[goal]
+		if done {
+			break
+		}
+	}
+
+	_, ok := parents[goal]
+	if !ok {
+		return nil, false
+	}
+	carriers := make([][2]TopoSpot, 0)
+	used := make(map[TopoSpot]bool)
+	for group := goal; group != start; group = parents[group].from {
+		carrier := parents[group].carrier
+		if used[carrier[0]] || used[carrier[1]] {
+			return nil, false
+		}
+		used[carrier[0]] = true
+		used[carrier[1]] = true
+		carriers = append(carriers, carrier)
+	}
+	return carriers, true
+}
+
+type Solver struct {
+	// The search gives up after looking at this many nodes. Zero means
+	// no limit.
+	MaxNodes int
+
+	// The search gives up after this long. Zero means no limit.
+	Seconds float64
+
+	// Keeps the transposition table between calls to Solve, which saves
+	// work when solving many related positions.
+	KeepTable bool
+
+	table map[int64]proofEntry
+	nodes int
+	start time.Time
+	aborted bool
+}
+
+type SolveResult struct {
+	// Whether the search finished. If not, the rest is meaningless.
+	Proven bool
+
+	// The player who wins with perfect play
+	Winner Color
+
+	// A winning move, if the player to move is the winner
+	Move NaiveSpot
+
+	// The number of positions in the proof, and the number the search
+	// looked at to find it
+	ProofSize int
+	Nodes int
+
+	Seconds float64
+}
+
+func (r SolveResult) String() string {
+	if !r.Proven {
+		return fmt.Sprintf("unproven after %d nodes in %.1fs", r.Nodes,
+			r.Seconds)
+	}
+	move := ""
+	if !r.Move.IsNotASpot() {
+		move = fmt.Sprintf(" with %s", r.Move)
+	}
+	return fmt.Sprintf("%s wins%s. proof size %d, %d nodes in %.1fs",
+		r.Winner.Name(), move, r.ProofSize, r.Nodes, r.Seconds)
+}
+
+// Evaluates a position without looking at its children. Returns the
+// entry and true if that decides it, or else the moves worth trying.
+func (s *Solver) evaluate(b *TopoBoard) (proofEntry, []TopoSpot, bool) {
+	win := proofEntry{0, proofInfinity, true, NotASpot}
+	loss := proofEntry{proofInfinity, 0, true, NotASpot}
+	me := b.ToMove
+	if b.Winner == me {
+		return win, nil, true
+	}
+	if b.Winner == -me {
+		return loss, nil, true
+	}
+
+	mine := b.winningSpots(me)
+	if len(mine) > 0 {
+		win.move = mine[0]
+		return win, nil, true
+	}
+	carriers, ok := b.bridgeConnection(me)
+	if ok {
+		win.move = carriers[0][0]
+		return win, nil, true
+	}
+	_, ok = b.bridgeConnection(-me)
+	if ok {
+		return loss, nil, true
+	}
+	theirs := b.winningSpots(-me)
+	if len(theirs) > 1 {
+		return loss, nil, true
+	}
+	if len(theirs) == 1 {
+		return proofEntry{}, theirs, false
+	}
+	return proofEntry{}, b.PossibleTopoSpotMoves(), false
+}
+
+// The hash of the position after the player to move plays at spot.
+func childKey(key int64, b *TopoBoard, spot TopoSpot) int64 {
+	key ^= whiteToMoveZobrist
+	if b.ToMove == Black {
+		return key ^ blackZobrist[spot]
+	}
+	return key ^ whiteZobrist[spot]
+}
+
+func (s *Solver) lookup(key int64) proofEntry {
+	entry, ok := s.table[key]
+	if !ok {
+		return proofEntry{1, 1, false, NotASpot}
+	}
+	return entry
+}
+
+func (s *Solver) outOfBudget() bool {
+	if s.MaxNodes > 0 && s.nodes >= s.MaxNodes {
+		return true
+	}
+	if s.Seconds > 0 && s.nodes % 1000 == 0 &&
+		SecondsSince(s.start) >= s.Seconds {
+		return true
+	}
+	return false
+}
+
+func addProofNumbers(a int64, b int64) int64 {
+	if a + b >= proofInfinity {
+		return proofInfinity
+	}
+	return a + b
+}
+
+// Searches until the node is proven or its numbers reach a threshold.
+func (s *Solver) mid(b *TopoBoard, key int64, phiLimit int64,
+	deltaLimit int64) {
+	entry := s.lookup(key)
+	if entry.phi >= phiLimit || entry.delta >= deltaLimit {
+		return
+	}
+	s.nodes++
+	if s.outOfBudget() {
+		s.aborted = true
+		return
+	}
+
+	decided, moves, ok := s.evaluate(b)
+	if ok {
+		s.table[key] = decided
+		return
+	}
+	keys := make([]int64, len(moves))
+	for i, move := range moves {
+		keys[i] = childKey(key, b, move)
+	}
+
+	for {
+		// A child's delta is our phi and vice versa
+		phi := proofInfinity
+		delta := int64(0)
+		best := -1
+		bestDelta := proofInfinity
+		secondDelta := proofInfinity
+		for i := range moves {
+			child := s.lookup(keys[i])
+			delta = addProofNumbers(delta, child.phi)
+			if child.delta < phi {
+				phi = child.delta
+			}
+			if best < 0 || child.delta < bestDelta {
+				secondDelta = bestDelta
+				bestDelta = child.delta
+				best = i
+			} else if child.delta < secondDelta {
+				secondDelta = child.delta
+			}
+		}
+		s.table[key] = proofEntry{phi, delta, false, NotASpot}
+		if phi >= phiLimit || delta >= deltaLimit || s.aborted {
+			return
+		}
+
+		bestChild := s.lookup(keys[best])
+		childPhiLimit := addProofNumbers(deltaLimit - delta, bestChild.phi)
+		childDeltaLimit := phiLimit
+		if secondDelta + 1 < childDeltaLimit {
+			childDeltaLimit = secondDelta + 1
+		}
+		child := b.Clone()
+		child.MakeMove(moves[best].NaiveSpot())
+		s.mid(child, keys[best], childPhiLimit, childDeltaLimit)
+	}
+}
+
+// Counts the positions in the proof that the player to move at b wins
+// or loses. Each position is only counted once.
+func (s *Solver) proofSize(b *TopoBoard, key int64,
+	counted map[int64]bool) int {
+	if counted[key] {
+		return 0
+	}
+	counted[key] = true
+	entry := s.lookup(key)
+	if entry.static {
+		return 1
+	}
+	_, moves, _ := s.evaluate(b)
+	answer := 1
+	for _, move := range moves {
+		k := childKey(key, b, move)
+		child := s.lookup(k)
+		if entry.phi == 0 && child.delta != 0 {
+			continue
+		}
+		next := b.Clone()
+		next.MakeMove(move.NaiveSpot())
+		answer += s.proofSize(next, k, counted)
+		if entry.phi == 0 {
+			break
+		}
+	}
+	return answer
+}
+
+// Finds the winner of the position, if it can within the budget.
+func (s *Solver) Solve(board Board) SolveResult {
+	if s.table == nil || !s.KeepTable {
+		s.table = make(map[int64]proofEntry)
+	}
+	s.nodes = 0
+	s.start = time.Now()
+	s.aborted = false
+
+	b := board.ToTopoBoard()
+	key := b.Zobrist()
+	s.mid(b, key, proofInfinity, proofInfinity)
+	result := SolveResult{
+		Move: MakeNaiveSpot(-1, -1),
+		Nodes: s.nodes,
+		Seconds: SecondsSince(s.start),
+	}
+	entry := s.lookup(key)
+	if s.aborted || (entry.phi != 0 && entry.delta != 0) {
+		return result
+	}
+
+	result.Proven = true
+	result.ProofSize = s.proofSize(b, key, make(map[int64]bool))
+	if entry.delta == 0 {
+		result.Winner = -b.ToMove
+		return result
+	}
+	result.Winner = b.ToMove
+	if entry.static {
+		if entry.move != NotASpot && !entry.move.isSpecialSpot() {
+			result.Move = entry.move.NaiveSpot()
+		}
+		return result
+	}
+	_, moves, _ := s.evaluate(b)
+	for _, move := range moves {
+		if s.lookup(childKey(key, b, move)).delta == 0 {
+			result.Move = move.NaiveSpot()
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,112 @@
+package hex
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// Solves by trying every move, for checking the solver.
+func bruteForceWinner(b *TopoBoard) Color {
+	if b.Winner != Empty {
+		return b.Winner
+	}
+	for _, move := range b.PossibleMoves() {
+		child := b.Clone()
+		child.MakeMove(move)
+		if bruteForceWinner(child) == b.ToMove {
+			return b.ToMove
+		}
+	}
+	return -b.ToMove
+}
+
+func TestSolveSmallBoards(t *testing.T) {
+	for size := 1; size <= 4; size++ {
+		s := Solver{}
+		result := s.Solve(NewSmallBoard(size))
+		if !result.Proven || result.Winner != Black {
+			t.Fatalf("size %d: expected a Black win but got %s", size, result)
+		}
+		if size > 3 {
+			continue
+		}
+		b := NewSmallBoard(size)
+		b.MakeMove(result.Move)
+		if bruteForceWinner(b.ToTopoBoard()) != Black {
+			t.Fatalf("size %d: %s is not a winning move", size, result.Move)
+		}
+	}
+}
+
+func TestSolveMatchesBruteForce(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		b := NewSmallBoard(4)
+		moves := b.PossibleMoves()
+		rand.Shuffle(len(moves), func(i, j int) {
+			moves[i], moves[j] = moves[j], moves[i]
+		})
+		for _, move := range moves[:8 + rand.Intn(4)] {
+			b.MakeMove(move)
+		}
+		topo := b.ToTopoBoard()
+		if topo.Winner != Empty {
+			continue
+		}
+		s := Solver{}
+		result := s.Solve(b)
+		expected := bruteForceWinner(topo)
+		if !result.Proven || result.Winner != expected {
+			b.Eprint()
+			t.Fatalf("expected %s to win but got %s", expected.Name(), result)
+		}
+	}
+}
+
+func TestSolvePuzzles(t *testing.T) {
+	unsolvable := make(map[string]bool)
+	for _, name := range UnsolvablePuzzles {
+		unsolvable[name] = true
+	}
+	for _, puzzle := range FindPuzzles("all") {
+		if unsolvable[puzzle.Name] {
+			// These should give up cleanly within the budget
+			s := Solver{MaxNodes: 20000}
+			result := s.Solve(puzzle.Board)
+			if result.Proven || result.Nodes > 20000 ||
+				!strings.HasPrefix(result.String(), "unproven") {
+				t.Fatalf("%s should be unproven, not %s", puzzle.Name, result)
+			}
+			continue
+		}
+		s := Solver{MaxNodes: 100000}
+		result := s.Solve(puzzle.Board)
+		if !result.Proven {
+			t.Fatalf("%s: %s", puzzle.Name, result)
+		}
+		if puzzle.Type == ClearMove {
+			continue
+		}
+		won := result.Winner == puzzle.Board.ToMove
+		if won != (puzzle.Type == DefiniteWin) {
+			t.Fatalf("%s is a %s but the solver says %s", puzzle.Name,
+				puzzle.Type, result)
+		}
+		if won && !puzzle.IsAnswer(result.Move) {
+			t.Fatalf("%s: %s is not an answer", puzzle.Name, result.Move)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	b := NewTopoBoard()
+	b.MakeMove(MakeNaiveSpot(0, 0))
+	c := b.Clone()
+	c.MakeMove(MakeNaiveSpot(1, 0))
+	if b.Get(MakeNaiveSpot(1, 0)) != Empty || len(b.History) != 1 {
+		t.Fatalf("changing a clone should not change the original")
+	}
+	if b.Zobrist() == c.Zobrist() || b.Zobrist() != b.Clone().Zobrist() {
+		t.Fatalf("bad zobrist hashes")
+	}
+}
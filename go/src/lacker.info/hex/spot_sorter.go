@@ -3,6 +3,7 @@ package hex
 import (
 	"log"
 	"sort"
+	"sync"
 	"time"
 )
 
@@ -15,13 +16,32 @@ higher scores.
 The theory is that eventually this should converge to something more
 intelligent than a shallow rave algorithm which doesn't do any move
 after the first one intelligently.
+
+Playouts depend on the ranking, which changes after every playout, so
+they can't be split up between goroutines without changing what the
+algorithm does. With more than one thread, the goroutines share one
+ranking. Each one copies it, plays a batch of playouts in that order,
+and then applies their updates under a lock. So a playout can miss the
+updates of the ones running alongside it. That doesn't match the
+single-threaded result, and it changes from run to run.
 */
 
+// How many playouts a goroutine plays from its copy of the ranking
+// before it updates the scores, when there's more than one thread.
+const spotSorterBatch = 10
+
 // The player
 type SpotSorter struct {
-	Seconds float64
+	SearchLimits
 	Quiet bool
 
+	// Gets told about progress during the search, if set
+	Observer SearchObserver
+
+	// How many goroutines do playouts. Zero means one. More than one
+	// doesn't match the single-threaded result.
+	Threads int
+
 	// ranked keeps the spots in sorted order.
 	// The scores start at zero. Spots that lose or aren't useful go
 	// negative; spots that win go positive.
@@ -31,6 +51,53 @@ type SpotSorter struct {
 	losses int
 }
 
+func (s *SpotSorter) SetObserver(observer SearchObserver) {
+	s.Observer = observer
+}
+
+func init() {
+	RegisterPlayerType(PlayerType{
+		Name: "ss",
+		Help: "spot sorter. ranks spots by how they do in playouts",
+		Options: []PlayerOption{
+			{"seconds", "5", "how long to think per move"},
+			{"quiet", "false", "whether to skip logging"},
+			{"threads", "1",
+				"how many goroutines to do playouts with. not the same as one"},
+		},
+		Make: func(o *PlayerOptions) Player {
+			return &SpotSorter{
+				SearchLimits: o.Limits(),
+				Quiet: o.Bool("quiet"),
+				Threads: o.Int("threads"),
+			}
+		},
+	})
+}
+
+// Summarizes the search so far. Since playouts move in rank order,
+// the top of the ranking is the expected line of play.
+func (s *SpotSorter) Progress(seconds float64) SearchProgress {
+	pv := make([]NaiveSpot, 0)
+	for index, scoredSpot := range s.ranked {
+		if index >= 10 {
+			break
+		}
+		pv = append(pv, scoredSpot.Spot.NaiveSpot())
+	}
+	winRate := 0.5
+	if s.wins + s.losses > 0 {
+		winRate = float64(s.wins) / float64(s.wins + s.losses)
+	}
+	return SearchProgress{
+		BestMove: pv[0],
+		WinRate: winRate,
+		Playouts: s.wins + s.losses,
+		Seconds: seconds,
+		PrincipalVariation: pv,
+	}
+}
+
 // Initialize from a particular board position.
 func (s *SpotSorter) Init(b Board) {
 	// Populate ranked
@@ -46,49 +113,108 @@ func (s *SpotSorter) Init(b Board) {
 	s.losses = 0
 }
 
-func (s SpotSorter) Play(b Board) (NaiveSpot, float64) {
-	start := time.Now()
-
-	s.Init(b)
-
-	// Run playouts in a loop until we run out of time
-	for i := 0; true; i++ {
-		// Check if we are out of time
-		if SecondsSince(start) > s.Seconds {
+// Plays out b by moving in the order of spots.
+func spotSorterPlayout(b Board, spots []TopoSpot) *TopoBoard {
+	playout := b.ToTopoBoard()
+	for _, spot := range spots {
+		playout.MakeMove(spot)
+		if playout.Winner != Empty {
 			break
 		}
+	}
+	return playout
+}
 
-		// Run the playout by moving in rank order.
-		playout := b.ToTopoBoard()
-		for _, move := range s.ranked {
-			playout.MakeMove(move.Spot)
-			if playout.Winner != Empty {
-				break
-			}
-		}
+// Counts a finished playout from b in the record and the scores. The
+// ranking needs sorting again afterwards.
+func (s *SpotSorter) update(b Board, playout *TopoBoard) {
+	// Update the overall win/loss score.
+	if playout.Winner == b.GetToMove() {
+		s.wins++
+	} else {
+		s.losses++
+	}
 
-		// Next, update the overall win/loss score.
-		winner := playout.Winner
-		if winner == b.GetToMove() {
-			s.wins++
+	// Update the scores for all spots.
+	for _, scoredSpot := range s.ranked {
+		if playout.Get(scoredSpot.Spot.NaiveSpot()) == playout.Winner {
+			// This counts all spots played by the winner as a win
+			scoredSpot.Score += 1.0
 		} else {
-			s.losses++
+			scoredSpot.Score -= 1.0
 		}
+		scoredSpot.Score /= 1.0001
+	}
+}
 
-		// Update the scores for all spots.
-		for _, scoredSpot := range s.ranked {
-			if playout.Get(scoredSpot.Spot.NaiveSpot()) == playout.Winner {
-				// This counts all spots played by the winner as a win
-				scoredSpot.Score += 1.0
-			} else {
-				scoredSpot.Score -= 1.0
+// Does playouts from b until the limits are reached.
+func (s *SpotSorter) run(b Board, start time.Time) {
+	clock := newProgressClock()
+	threads := Intmax(s.Threads, 1)
+	batch := 1
+	if threads > 1 {
+		batch = spotSorterBatch
+	}
+
+	// The lock covers the ranking, the record, and started, which
+	// counts the playouts that have been handed out.
+	var mutex sync.Mutex
+	started := 0
+	work := func() {
+		spots := make([]TopoSpot, len(s.ranked))
+		playouts := make([]*TopoBoard, 0, batch)
+		mutex.Lock()
+		defer mutex.Unlock()
+		for {
+			n := 0
+			for n < batch && !s.Reached(start, started, 0) {
+				n++
+				started++
+			}
+			if n == 0 {
+				return
 			}
-			scoredSpot.Score /= 1.0001
+			if clock.due(s.Observer) {
+				s.Observer.ObserveSearch(s.Progress(clock.seconds()))
+			}
+			for i, scoredSpot := range s.ranked {
+				spots[i] = scoredSpot.Spot
+			}
+
+			// Run the playouts by moving in rank order.
+			mutex.Unlock()
+			playouts = playouts[:0]
+			for i := 0; i < n; i++ {
+				playouts = append(playouts, spotSorterPlayout(b, spots))
+			}
+			mutex.Lock()
+
+			for _, playout := range playouts {
+				s.update(b, playout)
+			}
+
+			// Sort the possible moves by score.
+			sort.Stable(s.ranked)
 		}
+	}
 
-		// Sort the possible moves by score.
-		sort.Stable(s.ranked)
+	var wg sync.WaitGroup
+	for i := 1; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work()
+		}()
 	}
+	work()
+	wg.Wait()
+}
+
+func (s SpotSorter) Play(b Board) (NaiveSpot, float64) {
+	start := time.Now()
+
+	s.Init(b)
+	s.run(b, start)
 
 	winRate := float64(s.wins) / float64(s.wins + s.losses)
 
@@ -0,0 +1,108 @@
+package hex
+
+import (
+	"fmt"
+	"math"
+)
+
+/*
+The sequential probability ratio test decides between two hypotheses
+about how much stronger one player is than another, playing only as
+many games as it takes to be confident.
+
+H0 is that the player is Elo0 stronger, and H1 is that it is Elo1
+stronger. Alpha is the chance of accepting H1 when H0 is true, and
+Beta is the chance of accepting H0 when H1 is true. Hex has no draws,
+so each game is a coin flip with a win probability set by the elo
+difference.
+
+To gate a change, test the new version against the old one with
+something like Elo0 = 0 and Elo1 = 20. Accepting H1 means the change
+helped.
+*/
+
+type SPRTStatus int
+const (
+	SPRTContinue SPRTStatus = iota
+	SPRTAcceptH0
+	SPRTAcceptH1
+)
+
+func (s SPRTStatus) String() string {
+	switch s {
+	case SPRTContinue:
+		return "undecided"
+	case SPRTAcceptH0:
+		return "H0 accepted"
+	case SPRTAcceptH1:
+		return "H1 accepted"
+	}
+	panic("bad sprt status")
+}
+
+type SPRT struct {
+	Elo0 float64
+	Elo1 float64
+	Alpha float64
+	Beta float64
+
+	// The games played so far, from the point of view of the player
+	// being tested
+	Wins int
+	Losses int
+}
+
+// The expected score for a player who is elo stronger.
+func EloToWinRate(elo float64) float64 {
+	return 1.0 / (1.0 + math.Pow(10.0, -elo / 400.0))
+}
+
+func (s *SPRT) Validate() error {
+	if s.Elo1 <= s.Elo0 {
+		return fmt.Errorf("elo1 must be greater than elo0")
+	}
+	if s.Alpha <= 0 || s.Alpha >= 0.5 || s.Beta <= 0 || s.Beta >= 0.5 {
+		return fmt.Errorf("alpha and beta must be between 0 and 0.5")
+	}
+	return nil
+}
+
+func (s *SPRT) Add(won bool) {
+	if won {
+		s.Wins++
+	} else {
+		s.Losses++
+	}
+}
+
+// The log-likelihood ratio of H1 to H0 given the games so far.
+func (s *SPRT) LLR() float64 {
+	p0 := EloToWinRate(s.Elo0)
+	p1 := EloToWinRate(s.Elo1)
+	return float64(s.Wins) * math.Log(p1 / p0) +
+		float64(s.Losses) * math.Log((1.0 - p1) / (1.0 - p0))
+}
+
+// The LLR below which H0 is accepted and above which H1 is accepted.
+func (s *SPRT) Bounds() (float64, float64) {
+	return math.Log(s.Beta / (1.0 - s.Alpha)),
+		math.Log((1.0 - s.Beta) / s.Alpha)
+}
+
+func (s *SPRT) Status() SPRTStatus {
+	lower, upper := s.Bounds()
+	llr := s.LLR()
+	if llr <= lower {
+		return SPRTAcceptH0
+	}
+	if llr >= upper {
+		return SPRTAcceptH1
+	}
+	return SPRTContinue
+}
+
+func (s *SPRT) String() string {
+	lower, upper := s.Bounds()
+	return fmt.Sprintf("SPRT elo0=%.0f elo1=%.0f: %d-%d, LLR %.3f (%.3f, %.3f) %s",
+		s.Elo0, s.Elo1, s.Wins, s.Losses, s.LLR(), lower, upper, s.Status())
+}
@@ -0,0 +1,33 @@
+package hex
+
+import (
+	"testing"
+)
+
+func TestSPRT(t *testing.T) {
+	s := &SPRT{Elo0: 0, Elo1: 50, Alpha: 0.05, Beta: 0.05}
+	if s.Validate() != nil {
+		t.Fatalf("valid sprt failed to validate")
+	}
+	if s.Status() != SPRTContinue {
+		t.Fatalf("no games should be undecided")
+	}
+	for s.Status() == SPRTContinue {
+		s.Add(true)
+	}
+	if s.Status() != SPRTAcceptH1 {
+		t.Fatalf("winning every game should accept H1: %s", s)
+	}
+
+	s = &SPRT{Elo0: 0, Elo1: 50, Alpha: 0.05, Beta: 0.05}
+	for i := 0; i < 1000 && s.Status() == SPRTContinue; i++ {
+		s.Add(i % 2 == 0)
+	}
+	if s.Status() != SPRTAcceptH0 {
+		t.Fatalf("an even score should accept H0: %s", s)
+	}
+
+	if (&SPRT{Elo0: 10, Elo1: 0, Alpha: 0.05, Beta: 0.05}).Validate() == nil {
+		t.Fatalf("elo1 below elo0 should not validate")
+	}
+}
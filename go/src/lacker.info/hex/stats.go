@@ -0,0 +1,27 @@
+package hex
+
+import (
+	"math"
+)
+
+/*
+Statistics helpers for judging how players compare.
+*/
+
+// The z score for a 95% confidence interval
+const Z95 = 1.96
+
+// The 95% Wilson score interval for the true win rate, given a number
+// of wins out of a number of games.
+func WilsonInterval(wins int, games int) (float64, float64) {
+	if games <= 0 {
+		return 0.0, 1.0
+	}
+	n := float64(games)
+	p := float64(wins) / n
+	z2 := Z95 * Z95
+	center := (p + z2 / (2 * n)) / (1 + z2 / n)
+	spread := Z95 * math.Sqrt(p * (1 - p) / n + z2 / (4 * n * n)) /
+		(1 + z2 / n)
+	return math.Max(0.0, center - spread), math.Min(1.0, center + spread)
+}
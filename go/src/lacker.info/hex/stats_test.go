@@ -0,0 +1,20 @@
+package hex
+
+import (
+	"testing"
+)
+
+func TestWilsonInterval(t *testing.T) {
+	low, high := WilsonInterval(50, 100)
+	if low > 0.5 || high < 0.5 || high - low > 0.2 {
+		t.Fatalf("bad interval for 50/100: %.3f-%.3f", low, high)
+	}
+	low, high = WilsonInterval(10, 10)
+	if high != 1.0 || low < 0.6 {
+		t.Fatalf("bad interval for 10/10: %.3f-%.3f", low, high)
+	}
+	low, high = WilsonInterval(0, 0)
+	if low != 0.0 || high != 1.0 {
+		t.Fatalf("no games should give no information")
+	}
+}
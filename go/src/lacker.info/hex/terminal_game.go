@@ -0,0 +1,248 @@
+package hex
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+A TerminalGame is a human playing a bot by typing moves at a prompt.
+Spots are in standard notation, like "f6". Besides moves, the human can
+type:
+  undo    take back your last move, and the bot's reply
+  swap    take over the bot's first move, as the pie rule allows
+  hint    ask the hint player for a move
+  resign  give up
+*/
+
+type TerminalGame struct {
+	// The player types, as passed to GetPlayer
+	Bot string
+	Hint string
+
+	// The color the human starts as. Black moves first.
+	Human Color
+
+	// The board size. Zero means BoardSize.
+	Size int
+
+	// Where to append the game record, if set
+	RecordPath string
+
+	// The name to record the human's games under
+	HumanName string
+
+	bot Player
+	hint Player
+	moves []NaiveSpot
+	winRates []float64
+	human Color
+}
+
+// The state that undo goes back to.
+type terminalSnapshot struct {
+	moves []NaiveSpot
+	winRates []float64
+	human Color
+}
+
+// Since moves are only ever appended, a snapshot can share the slices.
+func (g *TerminalGame) snapshot() terminalSnapshot {
+	return terminalSnapshot{g.moves, g.winRates, g.human}
+}
+
+func (g *TerminalGame) size() int {
+	if g.Size == 0 {
+		return BoardSize
+	}
+	return g.Size
+}
+
+func (g *TerminalGame) board() *TopoBoard {
+	b := NewSmallBoard(g.size())
+	for _, move := range g.moves {
+		b.MakeMove(move)
+	}
+	return b.ToTopoBoard()
+}
+
+// Draws the board, with the last move in lower case.
+func (g *TerminalGame) Diagram() string {
+	b := g.board()
+	size := g.size()
+	letters := make([]string, size)
+	for c := 0; c < size; c++ {
+		letters[c] = string(rune('a' + c))
+	}
+	lines := []string{"   " + strings.Join(letters, " ")}
+	for r := 0; r < size; r++ {
+		line := fmt.Sprintf("%s%2d ", strings.Repeat(" ", r), r + 1)
+		for c := 0; c < size; c++ {
+			spot := MakeNaiveSpot(r, c)
+			mark := "."
+			switch b.Get(spot) {
+			case Black:
+				mark = "B"
+			case White:
+				mark = "W"
+			}
+			if len(g.moves) > 0 && spot == g.moves[len(g.moves) - 1] {
+				mark = strings.ToLower(mark)
+			}
+			if c > 0 {
+				line += " "
+			}
+			line += mark
+		}
+		lines = append(lines, fmt.Sprintf("%s %d", line, r + 1))
+	}
+	lines = append(lines, strings.Repeat(" ", size + 2) +
+		strings.Join(letters, " "))
+	lines = append(lines, "Black connects top to bottom, White left to right.")
+	return strings.Join(lines, "\n")
+}
+
+func (g *TerminalGame) record(winner Color) GameRecord {
+	record := GameRecord{
+		Black: g.Bot,
+		White: g.HumanName,
+		Moves: g.moves,
+		WinRates: g.winRates,
+		Winner: winner,
+	}
+	if g.human == Black {
+		record.Black, record.White = record.White, record.Black
+	}
+	if g.size() != BoardSize {
+		record.Opening = NewSmallBoard(g.size())
+	}
+	return record
+}
+
+// Plays the game, reading commands from in and writing to out.
+// Returns the finished game, which is also saved if RecordPath is set.
+func (g *TerminalGame) Run(in io.Reader, out io.Writer) (GameRecord, error) {
+	var err error
+	g.bot, err = LookupPlayer(g.Bot)
+	if err != nil {
+		return GameRecord{}, err
+	}
+	defer StopPondering(g.bot)
+	if g.Hint == "" {
+		g.Hint = g.Bot
+	}
+	g.hint, err = LookupPlayer(g.Hint)
+	if err != nil {
+		return GameRecord{}, err
+	}
+	defer StopPondering(g.hint)
+	if g.HumanName == "" {
+		g.HumanName = "human"
+	}
+	if g.size() < 1 || g.size() > BoardSize {
+		return GameRecord{}, fmt.Errorf("bad board size: %d", g.size())
+	}
+	g.human = g.Human
+	if g.human != White {
+		g.human = Black
+	}
+	g.moves = make([]NaiveSpot, 0)
+	g.winRates = make([]float64, 0)
+	undo := make([]terminalSnapshot, 0)
+	scanner := bufio.NewScanner(in)
+
+	for {
+		b := g.board()
+		if b.Winner != Empty {
+			fmt.Fprintln(out, g.Diagram())
+			if b.Winner == g.human {
+				fmt.Fprintln(out, "You win!")
+			} else {
+				fmt.Fprintln(out, "The bot wins.")
+			}
+			return g.finish(b.Winner)
+		}
+
+		if b.ToMove != g.human {
+			move, winRate := g.bot.Play(b.ToNaiveBoard())
+			if move.IsNotASpot() || b.Get(move) != Empty {
+				fmt.Fprintf(out, "The bot made an illegal move %s and forfeits.\n",
+					move)
+				return g.finish(g.human)
+			}
+			g.moves = append(g.moves, move)
+			g.winRates = append(g.winRates, winRate)
+			fmt.Fprintf(out, "The bot plays %s. It estimates its win rate at %.3f\n",
+				move.Notation(), winRate)
+			continue
+		}
+
+		fmt.Fprintln(out, g.Diagram())
+		fmt.Fprintf(out, "You are %s. Your move: ", g.human.Name())
+		if !scanner.Scan() {
+			err = scanner.Err()
+			if err == nil {
+				err = fmt.Errorf("input ended before the game did")
+			}
+			return GameRecord{}, err
+		}
+		command := strings.ToLower(strings.TrimSpace(scanner.Text()))
+
+		switch command {
+		case "":
+		case "resign":
+			fmt.Fprintln(out, "You resign. The bot wins.")
+			return g.finish(-g.human)
+		case "undo":
+			if len(undo) == 0 {
+				fmt.Fprintln(out, "There is nothing to undo.")
+				continue
+			}
+			last := undo[len(undo) - 1]
+			undo = undo[:len(undo) - 1]
+			g.moves = last.moves
+			g.winRates = last.winRates
+			g.human = last.human
+		case "swap":
+			if len(g.moves) != 1 || g.human != White {
+				fmt.Fprintln(out, "You can only swap as White, right after " +
+					"the first move.")
+				continue
+			}
+			undo = append(undo, g.snapshot())
+			g.human = Black
+			fmt.Fprintln(out, "You take over the first move. You are now Black.")
+		case "hint":
+			move, winRate := g.hint.Play(b.ToNaiveBoard())
+			fmt.Fprintf(out, "Hint: %s, with a win rate of %.3f\n",
+				move.Notation(), winRate)
+		default:
+			move, err := ParseNotation(command)
+			if err != nil || move.Row() >= g.size() || move.Col() >= g.size() {
+				fmt.Fprintln(out, "Type a spot like c3, or undo, swap, hint " +
+					"or resign.")
+				continue
+			}
+			if b.Get(move) != Empty {
+				fmt.Fprintf(out, "%s is taken.\n", move.Notation())
+				continue
+			}
+			undo = append(undo, g.snapshot())
+			g.moves = append(g.moves, move)
+			g.winRates = append(g.winRates, 0.0)
+		}
+	}
+}
+
+func (g *TerminalGame) finish(winner Color) (GameRecord, error) {
+	record := g.record(winner)
+	if g.RecordPath != "" {
+		err := AppendGameRecord(g.RecordPath, record)
+		if err != nil {
+			return record, err
+		}
+	}
+	return record, nil
+}
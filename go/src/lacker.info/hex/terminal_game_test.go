@@ -0,0 +1,61 @@
+package hex
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNotation(t *testing.T) {
+	spot := MakeNaiveSpot(5, 2)
+	if spot.Notation() != "c6" {
+		t.Fatalf("bad notation: %s", spot.Notation())
+	}
+	parsed, err := ParseNotation(" C6")
+	if err != nil || parsed != spot {
+		t.Fatalf("bad parse: %s %v", parsed, err)
+	}
+	for _, bad := range []string{"", "c", "6c", "z1", "a0", "a12"} {
+		_, err = ParseNotation(bad)
+		if err == nil {
+			t.Fatalf("expected an error parsing %q", bad)
+		}
+	}
+}
+
+func TestTerminalGame(t *testing.T) {
+	// On a 2x2 board, Black wins with b1 and then either a2 or b2.
+	// The random bot can't stop both.
+	game := TerminalGame{Bot: "random", Size: 2}
+	in := strings.NewReader("nonsense\nc1\nundo\nb1\nhint\nundo\nb1\na2\nb2\n")
+	out := &bytes.Buffer{}
+	record, err := game.Run(in, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Winner != Black || record.Black != "human" {
+		t.Fatalf("bad record: %+v\n%s", record, out)
+	}
+	if record.Moves[0] != MakeNaiveSpot(0, 1) || len(record.Moves) != 3 {
+		t.Fatalf("bad moves: %v\n%s", record.Moves, out)
+	}
+	if !strings.Contains(out.String(), "Hint: ") {
+		t.Fatalf("no hint in:\n%s", out)
+	}
+}
+
+func TestTerminalGameSwapAndResign(t *testing.T) {
+	game := TerminalGame{Bot: "random", Human: White, Size: 3}
+	in := strings.NewReader("swap\nresign\n")
+	out := &bytes.Buffer{}
+	record, err := game.Run(in, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Black != "human" || record.White != "random" {
+		t.Fatalf("swap should make the human Black: %+v", record)
+	}
+	if record.Winner != White || len(record.Moves) != 2 {
+		t.Fatalf("bad record after resigning: %+v\n%s", record, out)
+	}
+}
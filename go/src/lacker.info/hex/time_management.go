@@ -0,0 +1,239 @@
+package hex
+
+import (
+	"math"
+	"time"
+)
+
+/*
+Time management decides how long to think about a move when there's a
+clock for the whole game, rather than a set time for every move.
+
+A move gets an even share of the main time for the moves we expect to
+have left, plus whatever the increment or byo-yomi gives back for
+every move. Games rarely fill the board, so the moves left come from
+how long the game is likely to go on, which depends on how big the
+board is, how many moves have been made, and how much of it is still
+empty.
+
+That share is the target. A search that can tell how settled it is,
+like MCTS, stops before the target once its best move can't be
+overtaken in the time that's left, and goes on past it, up to the
+maximum, while its best move keeps changing. It stops right away once
+there's nothing left to decide: the position is proven, or every move
+but one is proven to lose. Moves that win on the spot, or that fail to
+block the opponent's one winning move, are proven before the search
+starts, since the search itself rarely goes back to a move that looks
+bad for long enough to prove it.
+*/
+
+// The clock as it is for the player to move.
+type GameClock struct {
+	// Seconds left on our main clock
+	Remaining float64
+
+	// Seconds added to our clock after each of our moves
+	Increment float64
+
+	// Once the main clock runs out, each move gets this many seconds,
+	// which are lost if they aren't used
+	ByoYomi float64
+
+	// How many moves both players have made so far
+	MoveNumber int
+}
+
+// How long to spend on one move.
+type TimePlan struct {
+	Target float64
+	Max float64
+}
+
+// About how much of the board gets filled by the end of a game
+const GameFill = 0.6
+
+// Even near the end, there might be this many more moves to make
+const MinMovesLeft = 3.0
+
+// The most a move can stretch its target by, while its best move is
+// unstable
+const MaxTimeStretch = 3.0
+
+// Kept back from every move, for talking to whoever runs the clock
+const ClockOverhead = 0.05
+
+// Plans the time for a move on b. A move that's forced gets no time.
+func PlanTime(clock GameClock, b Board) TimePlan {
+	empty := len(b.PossibleMoves())
+	if empty <= 1 {
+		return TimePlan{}
+	}
+
+	// Our share of the moves the game is likely to have left
+	length := GameFill * float64(empty + clock.MoveNumber)
+	movesLeft := (length - float64(clock.MoveNumber)) / 2.0
+	movesLeft = math.Max(movesLeft, MinMovesLeft)
+	movesLeft = math.Min(movesLeft, math.Ceil(float64(empty) / 2.0))
+
+	remaining := math.Max(clock.Remaining, 0)
+	perMove := clock.Increment + clock.ByoYomi
+	target := remaining / movesLeft + perMove
+	max := math.Min(target * MaxTimeStretch, remaining / 2.0 + perMove)
+
+	// The increment only gets added after the move, so it can't be spent
+	// on this one. Byo-yomi can, once the main time runs out.
+	available := remaining + clock.ByoYomi
+	target = math.Min(target, available)
+	max = math.Min(max, available)
+	return TimePlan{
+		Target: math.Max(target - ClockOverhead, 0),
+		Max: math.Max(max - ClockOverhead, 0),
+	}
+}
+
+// A ClockedPlayer plans its own time from the game clock, for its next
+// move.
+type ClockedPlayer interface {
+	Player
+	SetClock(clock GameClock)
+}
+
+// Tells a player about the clock for its next move on b. A player
+// that can't plan its own time just gets the target time. Returns
+// whether the player can use the clock at all.
+func UseClock(player Player, clock GameClock, b Board) bool {
+	clocked, ok := player.(ClockedPlayer)
+	if ok {
+		clocked.SetClock(clock)
+		return true
+	}
+	timed, ok := player.(TimedPlayer)
+	if ok {
+		timed.SetSeconds(PlanTime(clock, b).Target)
+		return true
+	}
+	return false
+}
+
+// Watches how a search's best move changes, to decide when it's done
+// with a time plan.
+type timeKeeper struct {
+	plan TimePlan
+	start time.Time
+
+	// The best move, and when it last changed
+	best NaiveSpot
+	changed float64
+}
+
+// How many rounds go by between checks on the best move
+const timeKeeperRounds = 100
+
+// Whether every move from n but one is proven to lose, so the player to
+// move has to make the other one.
+func forced(n *TreeNode) bool {
+	lost := 0
+	for _, edge := range n.Children {
+		if edge.Node.Proven == -n.Board.GetToMove() {
+			lost++
+		}
+	}
+	return lost == n.NumPossibleMoves - 1
+}
+
+// Proves the moves from root that one move settles: a move that wins
+// right away, or, if the opponent can win right away, every move that
+// doesn't take their winning spot.
+func proveThreats(root *TreeNode) {
+	if root.Proven != Empty {
+		return
+	}
+	b := root.Board.ToNaiveBoard()
+	toMove := b.ToMove
+	threats := make([]NaiveSpot, 0)
+	for _, spot := range b.PossibleMoves() {
+		after := b.ToNaiveBoard()
+		after.Set(spot, toMove)
+		if after.Winner() == toMove {
+			// The child is over, so it's proven when it's made
+			if root.Child(spot) == nil {
+				NewChild(root, spot)
+			}
+			root.prove()
+			return
+		}
+		after.Set(spot, -toMove)
+		if after.Winner() == -toMove {
+			threats = append(threats, spot)
+		}
+	}
+	if len(threats) == 0 {
+		return
+	}
+	for _, spot := range b.PossibleMoves() {
+		if len(threats) == 1 && spot == threats[0] {
+			continue
+		}
+		child := root.Child(spot)
+		if child == nil {
+			child = NewChild(root, spot)
+		}
+		child.Proven = -toMove
+	}
+	root.prove()
+}
+
+// Whether a search that has done this many rounds on root can stop.
+// With several threads, this only looks at the first thread's tree.
+func (k *timeKeeper) done(mcts *MonteCarloTreeSearch, root *TreeNode,
+	rounds int) bool {
+	elapsed := SecondsSince(k.start)
+	if elapsed >= k.plan.Max || root.NumPossibleMoves <= 1 ||
+		root.Proven != Empty {
+		return true
+	}
+
+	// A forced move still gets some playouts, for its win rate
+	if forced(root) && rounds > timeKeeperRounds {
+		return true
+	}
+	if len(root.Children) == 0 {
+		return false
+	}
+	best, _, _ := mcts.ExpectedBestMove(root)
+	if best != k.best {
+		k.best = best
+		k.changed = elapsed
+	}
+
+	// How far the move with the most playouts is ahead of the rest
+	most := root.MostSimulatedMove()
+	second := 0
+	for _, edge := range root.Children {
+		if edge.Move.NaiveSpot() != most {
+			second = Intmax(second, edge.Node.NumPlayouts())
+		}
+	}
+	lead := root.Child(most).NumPlayouts() - second
+
+	// If the best move has more playouts than any other move could catch
+	// up on by the deadline, nothing is going to overtake it.
+	deadline := k.plan.Target
+	if elapsed >= k.plan.Target {
+		deadline = k.plan.Max
+	}
+	rate := float64(rounds) / math.Max(elapsed, 0.001)
+	left := rate * (deadline - elapsed)
+	if best == most && float64(lead) > left {
+		return true
+	}
+	if elapsed < k.plan.Target {
+		return false
+	}
+
+	// Past the target, keep going while the best move is unstable: it
+	// changed in the last quarter of the search, or the search hasn't
+	// looked at it the most.
+	unstable := best != most || elapsed - k.changed < elapsed / 4.0
+	return !unstable
+}
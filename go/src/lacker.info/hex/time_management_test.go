@@ -0,0 +1,145 @@
+package hex
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlanTime(t *testing.T) {
+	board := NewNaiveBoard()
+	opening := PlanTime(GameClock{Remaining: 60}, board)
+	if opening.Target < 1.0 || opening.Target > 2.0 ||
+		opening.Max < opening.Target {
+		t.Fatalf("bad opening plan: %+v", opening)
+	}
+
+	// Later in the game there are fewer moves to share the time with
+	for i := 0; i < 40; i++ {
+		board.MakeMove(board.PossibleMoves()[0])
+	}
+	later := PlanTime(GameClock{Remaining: 60, MoveNumber: 40}, board)
+	if later.Target <= opening.Target {
+		t.Fatalf("expected more time later on than %+v, but got %+v", opening,
+			later)
+	}
+
+	// The increment comes after the move, so it can't be spent yet
+	increment := PlanTime(GameClock{Remaining: 1, Increment: 5},
+		NewNaiveBoard())
+	if increment.Target > 0.95 || increment.Max > 0.95 {
+		t.Fatalf("the plan uses more than is on the clock: %+v", increment)
+	}
+
+	byoYomi := PlanTime(GameClock{ByoYomi: 2}, board)
+	if byoYomi.Target != 1.95 || byoYomi.Max != 1.95 {
+		t.Fatalf("byo-yomi time should all get used: %+v", byoYomi)
+	}
+
+	forced := NewSmallBoard(2)
+	forced.MakeMove(MakeNaiveSpot(0, 0))
+	forced.MakeMove(MakeNaiveSpot(0, 1))
+	forced.MakeMove(MakeNaiveSpot(1, 0))
+	if PlanTime(GameClock{Remaining: 60}, forced) != (TimePlan{}) {
+		t.Fatalf("a forced move should get no time")
+	}
+}
+
+func TestTimeKeeper(t *testing.T) {
+	mcts := MakeMCTS(0)
+	mcts.V = 1
+	root := mcts.NewRoot(NewNaiveBoard())
+	best := NewChild(root, MakeNaiveSpot(5, 5))
+	best.BlackWins = 2500
+	best.WhiteWins = 500
+	other := NewChild(root, MakeNaiveSpot(0, 0))
+	other.BlackWins = 5
+	other.WhiteWins = 5
+
+	// A second in, at a thousand rounds a second, nothing can catch up
+	// with the best move's lead before the target
+	plan := TimePlan{Target: 2, Max: 6}
+	k := &timeKeeper{plan: plan, start: time.Now().Add(-time.Second)}
+	if !k.done(&mcts, root, 1000) {
+		t.Fatalf("the search should stop early")
+	}
+
+	other.BlackWins = 1400
+	other.WhiteWins = 1400
+	k = &timeKeeper{plan: plan, start: time.Now().Add(-time.Second)}
+	if k.done(&mcts, root, 1000) {
+		t.Fatalf("the search should go on while the other move can catch up")
+	}
+
+	// Past the target, an unstable best move gets more time
+	k = &timeKeeper{plan: plan, start: time.Now().Add(-3 * time.Second)}
+	if k.done(&mcts, root, 3000) {
+		t.Fatalf("the best move just changed, so the search should go on")
+	}
+	k.changed = 0
+	if !k.done(&mcts, root, 3000) {
+		t.Fatalf("the best move is stable, so the search should stop")
+	}
+}
+
+func TestMustBlock(t *testing.T) {
+	// Black is one stone short of connecting down the first column, and
+	// White has to block
+	board := NewNaiveBoard()
+	for row := 0; row < BoardSize; row++ {
+		if row != 5 {
+			board.Set(MakeNaiveSpot(row, 0), Black)
+		}
+		if row < BoardSize - 2 {
+			board.Set(MakeNaiveSpot(row, BoardSize - 1), White)
+		}
+	}
+	board.ToMove = White
+
+	mcts := MakeMCTS(0)
+	mcts.Quiet = true
+	clock := GameClock{Remaining: 600}
+	plan := PlanTime(clock, board)
+	mcts.SetClock(clock)
+	start := time.Now()
+	move, _ := mcts.Play(board)
+	if move != MakeNaiveSpot(5, 0) {
+		t.Fatalf("expected the block but got %s", move)
+	}
+	if SecondsSince(start) > plan.Target / 10.0 {
+		t.Fatalf("a forced move took %.2fs of a %.2fs target",
+			SecondsSince(start), plan.Target)
+	}
+
+	// With Black to move instead, Black wins right away
+	board.ToMove = Black
+	root := mcts.NewRoot(board)
+	proveThreats(root)
+	if root.Proven != Black {
+		t.Fatalf("Black should be proven to win")
+	}
+}
+
+func TestMCTSClock(t *testing.T) {
+	mcts := MakeMCTS(100)
+	mcts.Quiet = true
+	clock := GameClock{Remaining: 3}
+	plan := PlanTime(clock, NewNaiveBoard())
+	mcts.SetClock(clock)
+	start := time.Now()
+	mcts.Play(NewNaiveBoard())
+	if SecondsSince(start) > plan.Max + 0.2 || mcts.clock != nil {
+		t.Fatalf("the search should use the clock once, not its own time")
+	}
+
+	engine := NewEngine()
+	response := engine.Handle(EngineRequest{
+		Player: "sr:quiet=true",
+		Board: NewNaiveBoard(),
+		Clock: &clock,
+	}, nil)
+	sr := engine.players["sr:quiet=true"].(*ShallowRave)
+	if response.Error != "" || sr.Seconds != plan.Target {
+		t.Fatalf("the engine should give the planned time to players: %+v",
+			response)
+	}
+}
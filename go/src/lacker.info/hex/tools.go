@@ -51,16 +51,26 @@ func Fastlog(x int) float64 {
 
 // Shuffles a list of spots
 func ShuffleSpots(spots []NaiveSpot) {
+	ShuffleSpotsWith(globalRand, spots)
+}
+
+// Shuffles a list of spots with a particular source of randomness,
+// so that goroutines don't have to share one.
+func ShuffleSpotsWith(r *rand.Rand, spots []NaiveSpot) {
 	for i := range spots {
-    j := rand.Intn(i + 1)
+    j := r.Intn(i + 1)
     spots[i], spots[j] = spots[j], spots[i]
 	}
 }
 
 // Shuffles a list of topo spots
 func ShuffleTopoSpots(spots []TopoSpot) {
+	ShuffleTopoSpotsWith(globalRand, spots)
+}
+
+func ShuffleTopoSpotsWith(r *rand.Rand, spots []TopoSpot) {
 	for i := range spots {
-    j := rand.Intn(i + 1)
+    j := r.Intn(i + 1)
     spots[i], spots[j] = spots[j], spots[i]
 	}
 }
@@ -70,6 +80,20 @@ func Eprint(s string) {
 	fmt.Fprintf(os.Stderr, s)
 }
 
+// A source that draws from the global math/rand functions, so that
+// code written for a *rand.Rand can also use the global one.
+type globalSource struct{}
+
+func (globalSource) Int63() int64 {
+	return rand.Int63()
+}
+
+func (globalSource) Seed(seed int64) {
+	rand.Seed(seed)
+}
+
+var globalRand = rand.New(globalSource{})
+
 func Seed() {
 	rand.Seed(time.Now().UTC().UnixNano())
 }
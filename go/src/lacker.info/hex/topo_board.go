@@ -302,18 +302,29 @@ func (b *TopoBoard) SetTopoSpot(s TopoSpot, color Color) {
 	}
 }
 
-// Returns a zobrist hash of the board state.
-var blackZobrist [NumTopoSpots]int64
-var whiteZobrist [NumTopoSpots]int64
-var zobristInitialized bool = false
+// Random numbers for each color on each spot, for zobrist hashing.
+// They come from their own source with a fixed seed, so that they're
+// the same in every run, and hashes can be saved to files like opening
+// books. Changing the seed breaks those files.
+var blackZobrist, whiteZobrist, whiteToMoveZobrist = makeZobristTables()
+
+const zobristSeed = 1
+
+func makeZobristTables() ([NumTopoSpots]int64, [NumTopoSpots]int64,
+	int64) {
+	r := rand.New(rand.NewSource(zobristSeed))
+	var black, white [NumTopoSpots]int64
+	for spot := TopLeftCorner; spot <= BottomRightCorner; spot++ {
+		black[spot] = r.Int63()
+		white[spot] = r.Int63()
+	}
+	return black, white, r.Int63()
+}
+
+// Returns a zobrist hash of the board state, including whose move it
+// is.
 func (b TopoBoard) Zobrist() int64 {
 	var spot TopoSpot
-	if !zobristInitialized {
-		for spot = TopLeftCorner; spot <= BottomRightCorner; spot++ {
-			blackZobrist[spot] = rand.Int63()
-			whiteZobrist[spot] = rand.Int63()
-		}
-	}
 	var answer int64 = 0
 	for spot = TopLeftCorner; spot <= BottomRightCorner; spot++ {
 		switch b.Board[spot] {
@@ -323,9 +334,37 @@ func (b TopoBoard) Zobrist() int64 {
 			answer ^= whiteZobrist[spot]
 		}
 	}
+	if b.ToMove == White {
+		answer ^= whiteToMoveZobrist
+	}
 	return answer
 }
 
+// The change to a zobrist hash when color moves at spot, which also
+// changes whose move it is.
+func moveZobrist(color Color, spot NaiveSpot) int64 {
+	if color == Black {
+		return blackZobrist[spot.TopoSpot()] ^ whiteToMoveZobrist
+	}
+	return whiteZobrist[spot.TopoSpot()] ^ whiteToMoveZobrist
+}
+
+// Returns a copy of the board. Unlike Copy, this keeps the history.
+func (b *TopoBoard) Clone() *TopoBoard {
+	c := *b
+	c.GroupSpots = make([][]TopoSpot, len(b.GroupSpots))
+	for i, group := range b.GroupSpots {
+		if group != nil {
+			c.GroupSpots[i] = append([]TopoSpot{}, group...)
+		}
+	}
+	c.History = append([]TopoSpot{}, b.History...)
+	if b.Winner != Empty {
+		c.WinningPathSpots = c.GroupSpots[c.GroupId[b.WinningPathSpots[0]]]
+	}
+	return &c
+}
+
 func (b *TopoBoard) PossibleTopoSpotMoves() []TopoSpot {
 	answer := make([]TopoSpot, 0)
 	var spot TopoSpot
@@ -363,8 +402,12 @@ func (b *TopoBoard) MakeMove(s Spot) {
 // Returns the winner.
 // This mutates the board.
 func (b *TopoBoard) Playout() Color {
+	return b.PlayoutWith(globalRand)
+}
+
+func (b *TopoBoard) PlayoutWith(r *rand.Rand) Color {
 	moves := b.PossibleMoves()
-	ShuffleSpots(moves)
+	ShuffleSpotsWith(r, moves)
 
 	for _, move := range moves {
 		b.MakeMove(move)
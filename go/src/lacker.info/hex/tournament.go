@@ -0,0 +1,273 @@
+package hex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"runtime"
+	"sync"
+)
+
+/*
+A Tournament plays many players against each other and rates them.
+
+Every game is appended to a results file as it finishes, along with
+which game of its pairing it was. Running the same tournament again
+reads that file first and only plays the games that are still missing,
+so a tournament can be interrupted and resumed,
+and adding a new player or a new version of a player only plays the
+new pairings. Ratings are always computed from every game in the file.
+*/
+
+type TournamentPlayer struct {
+	// The name to record games under. Defaults to Player. Giving
+	// different versions of a bot different names lets them be
+	// compared in the same results file.
+	Name string
+
+	// The player type, as passed to GetPlayer
+	Player string
+}
+
+type Tournament struct {
+	Players []TournamentPlayer
+
+	// Either "roundrobin", where every player plays every other player,
+	// or "gauntlet", where Challenger plays everyone else.
+	Schedule string
+	Challenger string
+
+	// How many games each pair of players plays. Colors alternate.
+	GamesPerPairing int
+
+	// How many games to run at once. Zero means one per cpu.
+	Parallel int
+
+	// A file of boards in json, one per line, to start games from.
+	// Empty means every game starts from the empty board.
+	Openings string
+
+	// Where game records are stored
+	ResultsPath string
+
+	// The player rated zero. Empty means the average is zero.
+	Anchor string
+}
+
+// Reads a tournament config from a json file.
+func ReadTournament(path string) (*Tournament, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tournament{}
+	err = json.Unmarshal(content, t)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	for i, player := range t.Players {
+		if player.Name == "" {
+			t.Players[i].Name = player.Player
+		}
+	}
+	return t, t.Validate()
+}
+
+func (t *Tournament) Validate() error {
+	names := make(map[string]bool)
+	for _, player := range t.Players {
+		if names[player.Name] {
+			return fmt.Errorf("duplicate player name: %s", player.Name)
+		}
+		names[player.Name] = true
+		_, err := LookupPlayer(player.Player)
+		if err != nil {
+			return err
+		}
+	}
+	if len(t.Players) < 2 {
+		return fmt.Errorf("a tournament needs at least two players")
+	}
+	switch t.Schedule {
+	case "roundrobin":
+	case "gauntlet":
+		if !names[t.Challenger] {
+			return fmt.Errorf("the challenger is not a player: %s",
+				t.Challenger)
+		}
+	default:
+		return fmt.Errorf("unknown schedule: %s", t.Schedule)
+	}
+	if t.GamesPerPairing <= 0 {
+		return fmt.Errorf("GamesPerPairing must be positive")
+	}
+	if t.ResultsPath == "" {
+		return fmt.Errorf("a tournament needs a ResultsPath")
+	}
+	return nil
+}
+
+// The pairings to play, as a match each.
+func (t *Tournament) Matches() ([]*Match, error) {
+	var openings []*NaiveBoard
+	if t.Openings != "" {
+		var err error
+		openings, err = ReadNaiveBoards(t.Openings)
+		if err != nil {
+			return nil, err
+		}
+	}
+	newMatch := func(p1 TournamentPlayer, p2 TournamentPlayer) *Match {
+		return &Match{
+			Player1: p1.Name,
+			Player2: p2.Name,
+			Games: t.GamesPerPairing,
+			Alternate: true,
+			Openings: openings,
+		}
+	}
+
+	answer := make([]*Match, 0)
+	for i, p1 := range t.Players {
+		for j, p2 := range t.Players {
+			if j <= i {
+				continue
+			}
+			if t.Schedule == "gauntlet" && p1.Name != t.Challenger &&
+				p2.Name != t.Challenger {
+				continue
+			}
+			answer = append(answer, newMatch(p1, p2))
+		}
+	}
+	return answer, nil
+}
+
+// The game records in the results file, or none if it doesn't exist
+// yet.
+func (t *Tournament) Results() ([]GameRecord, error) {
+	_, err := os.Stat(t.ResultsPath)
+	if os.IsNotExist(err) {
+		return []GameRecord{}, nil
+	}
+	return ReadGameRecords(t.ResultsPath)
+}
+
+// The same for a pair of players whichever order they come in.
+func pairingKey(name1 string, name2 string) [2]string {
+	if name2 < name1 {
+		return [2]string{name2, name1}
+	}
+	return [2]string{name1, name2}
+}
+
+type tournamentGame struct {
+	match *Match
+	game int
+}
+
+// Plays whatever games are missing from the results file, then rates
+// everyone in it.
+func (t *Tournament) Run() (Ratings, error) {
+	specs := make(map[string]string)
+	for _, player := range t.Players {
+		specs[player.Name] = player.Player
+	}
+	matches, err := t.Matches()
+	if err != nil {
+		return nil, err
+	}
+	results, err := t.Results()
+	if err != nil {
+		return nil, err
+	}
+
+	// Find which games each pairing already has. Games run in parallel
+	// finish in any order, so after an interruption the missing ones
+	// can be anywhere. Records from before games were numbered just
+	// fill in the first missing games.
+	played := make(map[[2]string]map[int]bool)
+	unnumbered := make(map[[2]string]int)
+	for _, record := range results {
+		key := pairingKey(record.Black, record.White)
+		if record.Game == nil {
+			unnumbered[key]++
+			continue
+		}
+		if played[key] == nil {
+			played[key] = make(map[int]bool)
+		}
+		played[key][*record.Game] = true
+	}
+	games := make([]tournamentGame, 0)
+	for _, match := range matches {
+		key := pairingKey(match.Player1, match.Player2)
+		for game := 0; game < match.Games; game++ {
+			if played[key][game] {
+				continue
+			}
+			if unnumbered[key] > 0 {
+				unnumbered[key]--
+				continue
+			}
+			games = append(games, tournamentGame{match, game})
+		}
+	}
+	log.Printf("%d games already played, %d to go", len(results), len(games))
+
+	parallel := t.Parallel
+	if parallel <= 0 {
+		parallel = runtime.NumCPU()
+	}
+	queue := make(chan tournamentGame)
+	var mutex sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for g := range queue {
+				black, white := g.match.Player2, g.match.Player1
+				if g.match.Player1IsBlack(g.game) {
+					black, white = white, black
+				}
+				record, err := PlayGameBetween(specs[black], specs[white],
+					g.match.Opening(g.game))
+				record.Black = black
+				record.White = white
+				game := g.game
+				record.Game = &game
+
+				mutex.Lock()
+				if err == nil {
+					err = AppendGameRecord(t.ResultsPath, record)
+				}
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				if err == nil {
+					log.Printf("%s (Black) vs %s (White): %s wins",
+						black, white, record.Winner.Name())
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, g := range games {
+		queue <- g
+	}
+	close(queue)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	results, err = t.Results()
+	if err != nil {
+		return nil, err
+	}
+	return ComputeRatings(results, t.Anchor), nil
+}
@@ -0,0 +1,151 @@
+package hex
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTournament(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tournament")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(config, []byte(`{
+  "Players": [
+    {"Player": "random"},
+    {"Name": "random2", "Player": "random"},
+    {"Name": "random3", "Player": "random"}
+  ],
+  "Schedule": "roundrobin",
+  "GamesPerPairing": 2,
+  "Parallel": 2,
+  "ResultsPath": "` + filepath.Join(dir, "results.jsonl") + `"
+}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tournament, err := ReadTournament(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ratings, err := tournament.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ratings) != 3 {
+		t.Fatalf("expected 3 ratings but got %d", len(ratings))
+	}
+	for _, rating := range ratings {
+		if rating.Games != 4 {
+			t.Fatalf("%s should have played 4 games", rating.Name)
+		}
+	}
+
+	// Running again shouldn't play any more games
+	_, err = tournament.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := tournament.Results()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 6 {
+		t.Fatalf("expected 6 games but got %d", len(results))
+	}
+
+	// Switching to a gauntlet with more games only adds the new ones
+	tournament.Schedule = "gauntlet"
+	tournament.Challenger = "random"
+	tournament.GamesPerPairing = 3
+	_, err = tournament.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err = tournament.Results()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 8 {
+		t.Fatalf("expected 8 games but got %d", len(results))
+	}
+}
+
+func TestTournamentValidate(t *testing.T) {
+	tournament := Tournament{
+		Players: []TournamentPlayer{
+			{Name: "a", Player: "random"},
+			{Name: "b", Player: "nonsense"},
+		},
+		Schedule: "roundrobin",
+		GamesPerPairing: 1,
+		ResultsPath: "unused",
+	}
+	if tournament.Validate() == nil {
+		t.Fatalf("unknown player types should be rejected")
+	}
+	tournament.Players[1].Player = "random"
+	tournament.Schedule = "gauntlet"
+	if tournament.Validate() == nil {
+		t.Fatalf("a gauntlet needs a challenger")
+	}
+}
+
+func TestTournamentResumesMissingGames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tournament")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tournament := Tournament{
+		Players: []TournamentPlayer{
+			{Name: "a", Player: "random"},
+			{Name: "b", Player: "random"},
+		},
+		Schedule: "roundrobin",
+		GamesPerPairing: 4,
+		Parallel: 2,
+		ResultsPath: filepath.Join(dir, "results.jsonl"),
+	}
+
+	// An interrupted parallel run finished games 1 and 3 but not 0 and 2
+	for _, game := range []int{1, 3} {
+		game := game
+		record := PlayGame(Random{}, Random{}, nil)
+		record.Black, record.White = "b", "a"
+		record.Game = &game
+		err = AppendGameRecord(tournament.ResultsPath, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = tournament.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := tournament.Results()
+	if err != nil {
+		t.Fatal(err)
+	}
+	games := make(map[int]int)
+	for _, record := range results {
+		games[*record.Game]++
+		black := "a"
+		if *record.Game % 2 == 1 {
+			black = "b"
+		}
+		if record.Black != black {
+			t.Fatalf("game %d should have %s as Black", *record.Game, black)
+		}
+	}
+	if len(results) != 4 || len(games) != 4 {
+		t.Fatalf("expected games 0 to 3 once each but got %v", games)
+	}
+}
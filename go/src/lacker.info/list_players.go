@@ -0,0 +1,18 @@
+package main
+
+// Lists the player types that GetPlayer knows, with their options.
+// Any of these can be used wherever a player is expected, like
+//   go run arena.go mcts:seconds=3,v=500 sr:seconds=3
+
+import (
+	"fmt"
+
+	"lacker.info/hex"
+)
+
+func main() {
+	// Usage:
+	//   go run list_players.go
+
+	fmt.Println(hex.PlayerHelp())
+}
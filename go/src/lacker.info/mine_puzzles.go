@@ -0,0 +1,96 @@
+package main
+
+// Finds candidate puzzles in games, either from stored game records or
+// from new self-play games, and writes them out as .puzzle files.
+// Look them over before adding them to a suite, since the search that
+// finds them can be wrong.
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"lacker.info/hex"
+)
+
+func main() {
+	hex.Seed()
+
+	// Usage:
+	//   go run mine_puzzles.go [--analyzer mcts20] [--out puzzles] \
+	//     --records games.jsonl
+	//   go run mine_puzzles.go [--analyzer mcts20] [--out puzzles] \
+	//     --selfplay mcts1 [--games 10]
+
+	var analyzerp = flag.String("analyzer", "mcts20",
+		"the player that judges each position")
+	var recordsp = flag.String("records", "",
+		"file of game records to mine")
+	var selfplayp = flag.String("selfplay", "",
+		"the player to mine self-play games from")
+	var gamesp = flag.Int("games", 10, "number of self-play games")
+	var outp = flag.String("out", "mined", "directory to write puzzles to")
+	var prefixp = flag.String("prefix", "mined",
+		"the start of each puzzle name")
+	var thresholdp = flag.Float64("threshold", 0.95,
+		"the win rate that counts as a definite win")
+	var marginp = flag.Float64("margin", 0.3,
+		"how much better than the rest a clear move must be")
+
+	flag.Parse()
+	if (*recordsp == "") == (*selfplayp == "") {
+		log.Fatal("exactly one of --records and --selfplay is needed")
+	}
+
+	miner := hex.MakePuzzleMiner(*analyzerp)
+	miner.Threshold = *thresholdp
+	miner.Margin = *marginp
+
+	err := os.MkdirAll(*outp, 0755)
+	if err != nil {
+		log.Fatal(err)
+	}
+	save := func(puzzles []hex.Puzzle) {
+		for _, puzzle := range puzzles {
+			path := filepath.Join(*outp, puzzle.Name + hex.PuzzleExtension)
+			_, err := os.Stat(path)
+			if err == nil {
+				log.Printf("not overwriting %s", path)
+				continue
+			}
+			err = ioutil.WriteFile(path, []byte(hex.FormatPuzzle(puzzle)), 0644)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("wrote a %s puzzle to %s\n", puzzle.Type, path)
+		}
+	}
+
+	if *recordsp != "" {
+		records, err := hex.ReadGameRecords(*recordsp)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i, record := range records {
+			puzzles, err := miner.MineGame(record,
+				fmt.Sprintf("%s%dply", *prefixp, i))
+			if err != nil {
+				log.Fatal(err)
+			}
+			save(puzzles)
+		}
+		return
+	}
+
+	for i := 0; i < *gamesp; i++ {
+		puzzles, err := miner.MineSelfPlay(*selfplayp,
+			fmt.Sprintf("%s%dply", *prefixp, i))
+		if err != nil {
+			log.Fatal(err)
+		}
+		save(puzzles)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+// Builds the perfect-play database for a small board, and scores
+// players against it: how often they find a winning move, and how well
+// their win rates match who really wins.
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"lacker.info/hex"
+)
+
+func main() {
+	hex.Seed()
+
+	// Usage:
+	//   go run oracle.go --size 4 --build oracle4.db
+	//   go run oracle.go --size 5 [--db oracle5.db] --build oracle5.db
+	//   go run oracle.go --size 4 [--db oracle4.db] [--positions 200] \
+	//     --players sr:seconds=0.1,mcts:seconds=0.1
+	// There's no full database on 5x5, so building there saves what the
+	// oracle has proved, starting from --db if it's given.
+	// Players are separated by spaces if their specs have commas, like
+	//   --players "sr1 mcts:seconds=0.5,v=500"
+
+	var sizep = flag.Int("size", 4, "the board size")
+	var buildp = flag.String("build", "",
+		"build the whole database and save it here")
+	var dbp = flag.String("db", "", "a database saved with --build")
+	var playersp = flag.String("players", "", "the players to score")
+	var positionsp = flag.Int("positions", 100,
+		"the number of random positions to score players on")
+	var maxStonesp = flag.Int("maxstones", 0,
+		"the most stones in a random position. 0 means half the board")
+
+	flag.Parse()
+	if (*buildp == "") == (*playersp == "") {
+		log.Fatal("exactly one of --build and --players is needed")
+	}
+
+	var oracle *hex.Oracle
+	var err error
+	if *dbp != "" {
+		oracle, err = hex.ReadOracle(*dbp)
+		if err == nil && oracle.Size != *sizep {
+			err = fmt.Errorf("%s is for size %d, not %d", *dbp, oracle.Size,
+				*sizep)
+		}
+	} else {
+		oracle, err = hex.NewOracle(*sizep)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *buildp != "" {
+		start := hex.NewSmallBoard(oracle.Size)
+		oracle.Build()
+		err = oracle.Write(*buildp)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("saved %d positions to %s. winning first moves: %v",
+			oracle.NumPositions(), *buildp, oracle.WinningMoves(start))
+		return
+	}
+
+	maxStones := *maxStonesp
+	if maxStones == 0 {
+		maxStones = oracle.Size * oracle.Size / 2
+	}
+	positions := hex.RandomPositions(oracle.Size, *positionsp, maxStones)
+	specs := strings.Fields(*playersp)
+	if len(specs) == 1 && !strings.Contains(specs[0], ":") {
+		specs = strings.Split(specs[0], ",")
+	}
+	for _, spec := range specs {
+		player := hex.GetPlayer(spec)
+		score := oracle.Score(player, positions)
+		fmt.Fprintf(os.Stdout, "%s: %s\n", spec, score)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"log"
+	"os"
 
 	"lacker.info/hex"
 )
@@ -10,11 +11,41 @@ import (
 func main() {
 	hex.Seed()
 
+	// Usage:
+	//   go run play_hex.go playerType boardJSON
+	//   go run play_hex.go --server
+	//   go run play_hex.go --socket /tmp/hex.sock
+	//
+	// In server mode, requests are read as newline-delimited json.
+	// See hex.EngineRequest for the format.
+	var serverp = flag.Bool("server", false,
+		"serve requests on stdin instead of playing a single move")
+	var socketp = flag.String("socket", "",
+		"serve requests on a unix socket at this path")
+
+	flag.Parse()
+	args := flag.Args()
+
+	if *serverp || *socketp != "" {
+		if len(args) != 0 {
+			log.Fatal("play_hex takes no args in server mode")
+		}
+		engine := hex.NewEngine()
+		var err error
+		if *socketp != "" {
+			err = engine.ListenAndServe("unix", *socketp)
+		} else {
+			err = engine.Serve(os.Stdin, os.Stdout)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Load a board position from args.
 	// The first arg should be the player type to play.
 	// A board in json form should be passed as the second argument.
-	flag.Parse()
-	args := flag.Args()
 	if len(args) != 2 {
 		log.Fatal("expected exactly 2 args to play_hex")
 	}
@@ -0,0 +1,52 @@
+package main
+
+// Plays an interactive game against a bot in the terminal.
+// Type moves like f6, or undo, swap, hint, or resign.
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"lacker.info/hex"
+)
+
+func main() {
+	hex.Seed()
+
+	// Usage:
+	//   go run play_human.go [--white] [--size 11] [--hint mcts20] \
+	//     [--record human.jsonl] [--name you] botPlayer
+
+	var whitep = flag.Bool("white", false, "play White, so the bot moves first")
+	var sizep = flag.Int("size", hex.BoardSize, "the board size")
+	var hintp = flag.String("hint", "",
+		"the player to ask for hints. defaults to the bot")
+	var recordp = flag.String("record", "human.jsonl",
+		"file to append the game record to")
+	var namep = flag.String("name", "human", "your name in the game record")
+
+	flag.Parse()
+	args := flag.Args()
+	if len(args) != 1 {
+		log.Fatal("usage: go run play_human.go [flags] botPlayer")
+	}
+
+	game := hex.TerminalGame{
+		Bot: args[0],
+		Hint: *hintp,
+		Human: hex.Black,
+		Size: *sizep,
+		RecordPath: *recordp,
+		HumanName: *namep,
+	}
+	if *whitep {
+		game.Human = hex.White
+	}
+	_, err := game.Run(os.Stdin, os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Saved the game to %s\n", *recordp)
+}
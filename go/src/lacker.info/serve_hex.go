@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"lacker.info/hex"
+)
+
+func main() {
+	hex.Seed()
+
+	// Usage:
+	//   go run serve_hex.go [--addr localhost:8080] [--max_seconds 10]
+	//
+	// See hex.APIServer for the endpoints.
+	var addrp = flag.String("addr", "localhost:8080",
+		"address to listen on. keep it local unless you mean it")
+	var maxSecondsp = flag.Float64("max_seconds", 10,
+		"the most time any one request may think for")
+
+	flag.Parse()
+	if len(flag.Args()) > 0 {
+		log.Fatal("serve_hex takes no args")
+	}
+
+	server := hex.NewAPIServer(*maxSecondsp)
+	log.Fatal(server.ListenAndServe(*addrp))
+}
@@ -0,0 +1,58 @@
+package main
+
+// Finds out exactly who wins a position with perfect play, for
+// checking what puzzles claim or solving small boards.
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"lacker.info/hex"
+)
+
+func main() {
+	// Usage:
+	//   go run solve.go [--nodes 0] [--seconds 60] [--puzzles dir] puzzle
+	//   go run solve.go --size 5
+	// The puzzle can be a name or a tag. Some puzzles can't be solved,
+	// like needle, so by default each one gives up after a minute.
+
+	var nodesp = flag.Int("nodes", 0, "give up after this many nodes. 0 means never")
+	var secondsp = flag.Float64("seconds", 60,
+		"give up after this many seconds. 0 means never")
+	var sizep = flag.Int("size", 0, "solve the empty board of this size")
+	var puzzlesp = flag.String("puzzles", "",
+		"a directory of .puzzle files to add to the built-in puzzles")
+
+	flag.Parse()
+	args := flag.Args()
+
+	solver := hex.Solver{MaxNodes: *nodesp, Seconds: *secondsp}
+	if *sizep > 0 {
+		if len(args) != 0 {
+			log.Fatal("usage: go run solve.go --size 5")
+		}
+		fmt.Printf("%dx%d: %s\n", *sizep, *sizep,
+			solver.Solve(hex.NewSmallBoard(*sizep)))
+		return
+	}
+
+	if len(args) != 1 {
+		log.Fatal("usage: go run solve.go [flags] puzzle")
+	}
+	if *puzzlesp != "" {
+		err := hex.AddPuzzleDir(*puzzlesp)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	puzzles := hex.FindPuzzles(args[0])
+	if len(puzzles) == 0 {
+		log.Fatalf("no puzzle has the name or tag %s", args[0])
+	}
+	for _, puzzle := range puzzles {
+		result := solver.Solve(puzzle.Board)
+		fmt.Printf("%s (%s): %s\n", puzzle.Name, puzzle.Type, result)
+	}
+}
@@ -12,9 +12,11 @@ func main() {
 	hex.Seed()
 
 	// Usage:
-	//   go run solve_puzzles.go [--debug] playerName puzzleName
+	//   go run solve_puzzles.go [--debug] [--puzzles dir] playerName puzzleName
 
 	var debugp = flag.Bool("debug", false, "show debugging info")
+	var puzzlesp = flag.String("puzzles", "",
+		"a directory of .puzzle files to add to the built-in puzzles")
 
 	flag.Parse()
 	args := flag.Args()
@@ -24,6 +26,13 @@ func main() {
 	playerName := args[0]
 	puzzleName := args[1]
 
+	if *puzzlesp != "" {
+		err := hex.AddPuzzleDir(*puzzlesp)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if puzzleName == "gauntlet" {
 		hex.RunGauntlet(playerName)
 		return
@@ -0,0 +1,42 @@
+package main
+
+// Runs a tournament between many players and rates them.
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"lacker.info/hex"
+)
+
+func main() {
+	hex.Seed()
+
+	// Usage:
+	//   go run tournament.go config.json
+	//
+	// See hex.Tournament for the config format. For example:
+	// {
+	//   "Players": [{"Player": "sr1"}, {"Player": "mcts1"}],
+	//   "Schedule": "roundrobin",
+	//   "GamesPerPairing": 20,
+	//   "ResultsPath": "tournament.jsonl"
+	// }
+
+	flag.Parse()
+	args := flag.Args()
+	if len(args) != 1 {
+		log.Fatal("usage: go run tournament.go config.json")
+	}
+
+	tournament, err := hex.ReadTournament(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	ratings, err := tournament.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(ratings)
+}
@@ -25,24 +25,67 @@ def partcrazy(b):
   return montecarlo(b)
 
 """
-Constructs a go player that will shell to go with the given player type
+Constructs a go player that will shell to go with the given player type.
+With ponder, it keeps thinking during the opponent's turn.
 """
-def go_player(player_type):
-  return lambda b: go_shell(player_type, b)
+def go_player(player_type, ponder=False):
+  return lambda b: go_shell(player_type, b, ponder)
   
 """
-Shells out to go to play a move.
+The go engine server, started the first time a go player moves.
+It keeps running so that go players stay alive between moves.
 """
-def go_shell(player_type, b):
+engine = None
+engine_requests = 0
+
+def go_engine():
+  global engine
+  if engine is None:
+    fname = board.__file__ + "/../../go/src/lacker.info/play_hex.go"
+    fname = os.path.abspath(fname)
+    engine = subprocess.Popen(["go", "run", fname, "--server"],
+                              stdin=subprocess.PIPE, stdout=subprocess.PIPE)
+  return engine
+
+"""
+Sends a request to the go engine server and returns its response.
+"""
+def go_request(request):
+  global engine_requests
+  engine_requests += 1
+  request["Id"] = str(engine_requests)
+  server = go_engine()
+  server.stdin.write(json.dumps(request) + "\n")
+  server.stdin.flush()
+  response = json.loads(server.stdout.readline())
+  if response.get("Error"):
+    raise Exception(response["Error"])
+  return response
+
+"""
+Asks the go engine server to play a move.
+With ponder, the player thinks on the position after its move until
+the next request.
+"""
+def go_shell(player_type, b, ponder=False):
   print player_type, "is thinking"
-  fname = board.__file__ + "/../../go/src/lacker.info/play_hex.go"
-  fname = os.path.abspath(fname)
-  output = subprocess.check_output([
-    "go", "run", fname, player_type, b.to_json()])
-  json_spot = json.loads(output)
+  response = go_request({
+    "Player": player_type,
+    "Board": json.loads(b.to_json()),
+    "Ponder": ponder,
+    })
+  json_spot = response["Move"]
   answer = json_spot["Row"], json_spot["Col"]
   print player_type, "played", answer
   return answer
+
+"""
+Stops any go player from pondering, for when the game is over.
+Does nothing if the engine was never started.
+"""
+def go_stop_pondering():
+  if engine is not None:
+    go_request({"StopPondering": True})
   
 """
 Does treeless RAVE algorithm.
@@ -185,10 +228,10 @@ def save_history(b, filename="games.csv"):
   f.write(encoded + "\n")
   f.close()
   
-def make_player_by_type(viewer, color, board, player_type):
+def make_player_by_type(viewer, color, board, player_type, ponder=False):
   if player_type == "human":
     return make_human(viewer, color, board)
-  return make_computer(go_player(player_type), color, board)
+  return make_computer(go_player(player_type, ponder), color, board)
 
   
 if __name__ == "__main__":
@@ -217,12 +260,17 @@ if __name__ == "__main__":
 
   first_move = (1, 1)
   
-  make_player_by_type(v, board.BLACK, b, players[0])
-  make_player_by_type(v, board.WHITE, b, players[1])
+  # Bots only ponder against humans. Against each other, every move
+  # would stop the other bot's pondering anyway.
+  make_player_by_type(v, board.BLACK, b, players[0],
+                      ponder=(players[1] == "human"))
+  make_player_by_type(v, board.WHITE, b, players[1],
+                      ponder=(players[0] == "human"))
 
   # Starts a new game after this one is over
   def check_for_win():
     if b.to_move == board.EMPTY:
+      go_stop_pondering()
       winner = b.winner()
       wins[winner] = wins.get(winner, 0) + 1
       print "%s (Black): %d - %s (White): %d" % (